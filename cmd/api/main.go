@@ -5,6 +5,7 @@ import (
 	"log"
 
 	"reg/internal/config"
+	email "reg/internal/emails"
 	"reg/internal/server"
 )
 
@@ -36,6 +37,10 @@ func main() {
 
 	config.InitLogger()
 	config.InitSMTP()
+	email.InitMailer()
+	if err := email.InitTemplates(); err != nil {
+		log.Fatalf("Failed to load email templates: %v", err)
+	}
 	config.InitializeFirebase()
 
 	server := server.NewServer()
@@ -0,0 +1,90 @@
+package bounces
+
+import (
+	"context"
+	"log"
+	"os"
+	"reg/internal/database"
+	"sync"
+	"time"
+)
+
+// How often the bounce mailbox is read
+const scanInterval = 5 * time.Minute
+
+// Result counts what a scan of the bounce mailbox found.
+type Result struct {
+	Messages int `json:"messages"`
+	Bounces  int `json:"bounces"`
+}
+
+// scanMu keeps the background scan and one started by an admin apart.
+var scanMu sync.Mutex
+
+// mailbox is the maildir or mbox file bounces are delivered to, set by
+// BOUNCE_MAILBOX.
+func mailbox() string {
+	return os.Getenv("BOUNCE_MAILBOX")
+}
+
+// Enabled reports whether a bounce mailbox is configured.
+func Enabled() bool {
+	return mailbox() != ""
+}
+
+// Start scans the bounce mailbox now and then every few minutes. It does
+// nothing when no mailbox is configured.
+func Start() {
+	if !Enabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(scanInterval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := Scan(); err != nil {
+				log.Printf("Failed to scan bounce mailbox: %v\n", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// Scan reads the delivery status notifications in the bounce mailbox and
+// marks the users they report as undeliverable.
+func Scan() (Result, error) {
+	scanMu.Lock()
+	defer scanMu.Unlock()
+
+	var result Result
+	messages, err := readMailbox(mailbox())
+	if err != nil {
+		return result, err
+	}
+
+	ctx := context.Background()
+	for _, m := range messages {
+		key, bounces, err := Parse(m.raw)
+		if err != nil {
+			// Mail that cannot be read is left for a person to look at
+			log.Printf("Skipping unreadable message in bounce mailbox: %v\n", err)
+			continue
+		}
+		for _, b := range bounces {
+			recorded, err := database.RecordBounce(ctx, key, b)
+			if err != nil {
+				return result, err
+			}
+			if recorded {
+				log.Printf("Email to %s bounced: %s %s\n", b.Email, b.Status, b.Diagnostic)
+				result.Bounces++
+			}
+		}
+		if err := m.done(); err != nil {
+			return result, err
+		}
+		result.Messages++
+	}
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package bounces
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"reg/internal/model"
+	"strings"
+)
+
+// Parse reads a delivery status notification (RFC 3464) and returns a key
+// identifying it and the recipients it reports as failed. Messages that are
+// not notifications, like auto-replies, have no failed recipients.
+func Parse(raw []byte) (string, []model.Bounce, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := strings.TrimSpace(msg.Header.Get("Message-ID"))
+	if key == "" {
+		sum := sha256.Sum256(raw)
+		key = hex.EncodeToString(sum[:])
+	}
+
+	status, err := deliveryStatus(msg.Header.Get("Content-Type"), msg.Body)
+	if err != nil || status == nil {
+		return key, nil, err
+	}
+	bounces, err := failedRecipients(status)
+	return key, bounces, err
+}
+
+// deliveryStatus finds the message/delivery-status part of a message, nil
+// when there is none.
+func deliveryStatus(contentType string, body io.Reader) ([]byte, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, nil
+	}
+	switch {
+	case mediaType == "message/delivery-status":
+		return io.ReadAll(body)
+	case strings.HasPrefix(mediaType, "multipart/"):
+		r := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := r.NextPart()
+			if err == io.EOF {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			status, err := deliveryStatus(part.Header.Get("Content-Type"), part)
+			if err != nil || status != nil {
+				return status, err
+			}
+		}
+	}
+	return nil, nil
+}
+
+// failedRecipients reads the per-recipient fields of a delivery status,
+// which follow the per-message fields, each group ending in a blank line.
+func failedRecipients(status []byte) ([]model.Bounce, error) {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(status)))
+	var bounces []model.Bounce
+	for {
+		fields, err := r.ReadMIMEHeader()
+		if recipient := address(fields.Get("Final-Recipient")); recipient != "" && strings.EqualFold(strings.TrimSpace(fields.Get("Action")), "failed") {
+			bounces = append(bounces, model.Bounce{
+				Email:      recipient,
+				Status:     strings.TrimSpace(fields.Get("Status")),
+				Diagnostic: diagnostic(fields.Get("Diagnostic-Code")),
+			})
+		}
+		if errors.Is(err, io.EOF) {
+			return bounces, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// address is the address of a recipient field like "rfc822; user@host".
+func address(field string) string {
+	if i := strings.Index(field, ";"); i >= 0 {
+		field = field[i+1:]
+	}
+	field = strings.Trim(strings.TrimSpace(field), "<>")
+	if !strings.Contains(field, "@") {
+		return ""
+	}
+	return strings.ToLower(field)
+}
+
+// diagnostic is the text of a diagnostic code like "smtp; 550 5.1.1 ...".
+func diagnostic(field string) string {
+	if i := strings.Index(field, ";"); i >= 0 {
+		field = field[i+1:]
+	}
+	return strings.Join(strings.Fields(field), " ")
+}
@@ -0,0 +1,99 @@
+package bounces
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const dsn = "From: Mail Delivery System <MAILER-DAEMON@mail.example.com>\r\n" +
+	"To: esummit@ecelliith.org.in\r\n" +
+	"Subject: Undelivered Mail Returned to Sender\r\n" +
+	"Message-ID: <20250101.ABC@mail.example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=delivery-status; boundary=\"B\"\r\n" +
+	"\r\n" +
+	"--B\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Your message could not be delivered.\r\n" +
+	"--B\r\n" +
+	"Content-Type: message/delivery-status\r\n" +
+	"\r\n" +
+	"Reporting-MTA: dns; mail.example.com\r\n" +
+	"Arrival-Date: Wed, 1 Jan 2025 10:00:00 +0530\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; <Typo@Gmial.com>\r\n" +
+	"Original-Recipient: rfc822; Typo@Gmial.com\r\n" +
+	"Action: failed\r\n" +
+	"Status: 5.1.1\r\n" +
+	"Diagnostic-Code: smtp; 550 5.1.1 The email account\r\n" +
+	"    that you tried to reach does not exist\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; slow@example.com\r\n" +
+	"Action: delayed\r\n" +
+	"Status: 4.4.1\r\n" +
+	"--B\r\n" +
+	"Content-Type: text/rfc822-headers\r\n" +
+	"\r\n" +
+	"To: typo@gmial.com\r\n" +
+	"--B--\r\n"
+
+func TestParse(t *testing.T) {
+	key, bounces, err := Parse([]byte(dsn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "<20250101.ABC@mail.example.com>" {
+		t.Errorf("key = %q", key)
+	}
+	if len(bounces) != 1 {
+		t.Fatalf("got %d bounces, want only the failed recipient: %+v", len(bounces), bounces)
+	}
+	b := bounces[0]
+	if b.Email != "typo@gmial.com" || b.Status != "5.1.1" {
+		t.Errorf("bounce = %+v", b)
+	}
+	if b.Diagnostic != "550 5.1.1 The email account that you tried to reach does not exist" {
+		t.Errorf("diagnostic = %q", b.Diagnostic)
+	}
+}
+
+func TestParseIgnoresOtherMail(t *testing.T) {
+	reply := "From: someone@example.com\r\nSubject: Out of office\r\nContent-Type: text/plain\r\n\r\nAway until Monday.\r\n"
+	key, bounces, err := Parse([]byte(reply))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bounces) != 0 {
+		t.Errorf("got bounces from an auto-reply: %+v", bounces)
+	}
+	if key == "" {
+		t.Error("message without a Message-ID has no key")
+	}
+}
+
+func TestReadMbox(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bounces.mbox")
+	mbox := "From MAILER-DAEMON Wed Jan  1 10:00:00 2025\n" + strings.ReplaceAll(dsn, "\r\n", "\n") + "\n" +
+		"From someone@example.com Wed Jan  1 11:00:00 2025\n" +
+		"Subject: hi\n\n>From the desk of someone\n"
+	if err := os.WriteFile(path, []byte(mbox), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := readMailbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	if _, bounces, err := Parse(messages[0].raw); err != nil || len(bounces) != 1 {
+		t.Errorf("first message: %d bounces, %v", len(bounces), err)
+	}
+	if !strings.Contains(string(messages[1].raw), "\r\nFrom the desk") {
+		t.Errorf("quoted From line was not unescaped: %q", messages[1].raw)
+	}
+}
@@ -0,0 +1,103 @@
+package bounces
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// message is a mail read from the bounce mailbox. done is called once it
+// has been processed.
+type message struct {
+	raw  []byte
+	done func() error
+}
+
+// readMailbox reads the messages of a maildir, when path is a directory, or
+// of an mbox file.
+func readMailbox(path string) ([]message, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return readMaildir(path)
+	}
+	return readMbox(path)
+}
+
+// readMaildir reads the messages in new/. Processed messages are moved to
+// cur/ and marked seen, as a mail client would.
+func readMaildir(dir string) ([]message, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "new", "*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var messages []message
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		cur := filepath.Join(dir, "cur", filepath.Base(file)+":2,S")
+		messages = append(messages, message{raw: raw, done: func() error { return os.Rename(file, cur) }})
+	}
+	return messages, nil
+}
+
+// fromLine starts a message in an mbox, quotedFrom is a body line escaped so
+// it does not.
+var (
+	fromLine   = regexp.MustCompile(`^From `)
+	quotedFrom = regexp.MustCompile(`^>+From `)
+)
+
+// readMbox reads every message of an mbox file. The file is left as it is,
+// messages already recorded are skipped by their key.
+func readMbox(path string) ([]message, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		messages []message
+		current  *bytes.Buffer
+	)
+	flush := func() {
+		if current != nil && current.Len() > 0 {
+			messages = append(messages, message{raw: current.Bytes(), done: func() error { return nil }})
+		}
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if fromLine.Match(line) {
+			flush()
+			current = &bytes.Buffer{}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if quotedFrom.Match(line) {
+			line = line[1:]
+		}
+		current.Write(line)
+		current.WriteString("\r\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return messages, nil
+}
@@ -0,0 +1,128 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"reg/internal/database"
+	"reg/internal/model"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Event is an entry of the E-Summit calendar.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+}
+
+const (
+	uidDomain = "ecell.iith.ac.in"
+	venue     = "IIT Hyderabad, Kandi, Sangareddy, Telangana 502284"
+)
+
+// event is the calendar entry of a scheduled day or session.
+func event(s model.ScheduleEvent) Event {
+	return Event{
+		UID:         "esummit25-" + s.Code + "@" + uidDomain,
+		Summary:     s.Summary,
+		Description: s.Description,
+		Location:    venue,
+		Start:       s.Start,
+		End:         s.End,
+	}
+}
+
+// For returns the event days of a schedule and the sessions of the given
+// entitlement codes, in the order of the schedule. Entitlements that run
+// throughout the event, like the startup fair, have no slot of their own.
+func For(schedule []model.ScheduleEvent, codes []string) []Event {
+	events := []Event{}
+	for _, s := range schedule {
+		if s.Kind == "day" || slices.Contains(codes, s.Code) {
+			events = append(events, event(s))
+		}
+	}
+	return events
+}
+
+// ForTier returns the event days and the sessions a tier includes.
+func ForTier(ctx context.Context, title string) ([]Event, error) {
+	schedule, err := database.GetSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if title == "" {
+		return For(schedule, nil), nil
+	}
+	entitlements, err := database.GetEntitlements(ctx, title)
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]string, len(entitlements))
+	for i, e := range entitlements {
+		codes[i] = e.Code
+	}
+	return For(schedule, codes), nil
+}
+
+// ICS writes events as an iCalendar file (RFC 5545) that calendar apps can
+// import. The UIDs stay the same, so importing it again updates the
+// events instead of adding copies.
+func ICS(events []Event) []byte {
+	var b bytes.Buffer
+	line := func(s string) { writeFolded(&b, s) }
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//E-Cell IIT Hyderabad//E-Summit 2025//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:E-Summit 2025")
+	for _, e := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + e.UID)
+		line("DTSTAMP:" + stamp)
+		line("DTSTART:" + e.Start.UTC().Format("20060102T150405Z"))
+		line("DTEND:" + e.End.UTC().Format("20060102T150405Z"))
+		line("SUMMARY:" + escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION:" + escape(e.Description))
+		}
+		if e.Location != "" {
+			line("LOCATION:" + escape(e.Location))
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.Bytes()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// escape makes text safe for an iCalendar property value.
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// writeFolded writes a content line, folded at 75 bytes as RFC 5545 asks
+// without splitting a UTF-8 character.
+func writeFolded(b *bytes.Buffer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// The leading space of a continuation line counts towards its length
+		limit = 74
+	}
+	b.WriteString(s + "\r\n")
+}
@@ -0,0 +1,79 @@
+package calendar
+
+import (
+	"bytes"
+	"reg/internal/model"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+var ist = time.FixedZone("IST", 5*60*60+30*60)
+
+func at(day, hour, minute int) time.Time {
+	return time.Date(2025, time.February, day, hour, minute, 0, 0, ist)
+}
+
+var testSchedule = []model.ScheduleEvent{
+	{Code: "day-1", Kind: "day", Summary: "E-Summit 2025 - Day 1", Start: at(8, 9, 0), End: at(8, 18, 0)},
+	{Code: "networking-dinner", Kind: "session", Summary: "E-Summit 2025 - Networking Dinner", Start: at(8, 19, 30), End: at(8, 22, 0)},
+	{Code: "day-2", Kind: "day", Summary: "E-Summit 2025 - Day 2", Start: at(9, 9, 0), End: at(9, 18, 0)},
+}
+
+func TestForAddsTierSessions(t *testing.T) {
+	events := For(testSchedule, []string{"speaker-sessions", "networking-dinner"})
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want the two days and the dinner", len(events))
+	}
+	if events[1].UID != "esummit25-networking-dinner@ecell.iith.ac.in" {
+		t.Errorf("dinner on day 1 is not between the days: %+v", events)
+	}
+
+	if got := For(testSchedule, nil); len(got) != 2 {
+		t.Errorf("For(nil) returned %d events, want the two days", len(got))
+	}
+}
+
+func TestICS(t *testing.T) {
+	ics := string(ICS(For(testSchedule, []string{"networking-dinner"})))
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:esummit25-day-1@ecell.iith.ac.in\r\n",
+		// 9:00 IST is 3:30 UTC
+		"DTSTART:20250208T033000Z\r\n",
+		"SUMMARY:E-Summit 2025 - Networking Dinner\r\n",
+		`LOCATION:IIT Hyderabad\, Kandi\, Sangareddy\, Telangana 502284`,
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("calendar is missing %q", want)
+		}
+	}
+	if strings.Count(ics, "BEGIN:VEVENT") != 3 {
+		t.Errorf("calendar has %d events, want 3", strings.Count(ics, "BEGIN:VEVENT"))
+	}
+
+	for _, line := range strings.Split(ics, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 bytes: %q", line)
+		}
+	}
+}
+
+func TestWriteFoldedKeepsCharacters(t *testing.T) {
+	var b bytes.Buffer
+	s := "SUMMARY:" + strings.Repeat("é", 100)
+	writeFolded(&b, s)
+
+	for _, line := range strings.Split(b.String(), "\r\n") {
+		if !utf8.ValidString(line) {
+			t.Errorf("folding split a character: %q", line)
+		}
+	}
+	unfolded := strings.ReplaceAll(strings.TrimSuffix(b.String(), "\r\n"), "\r\n ", "")
+	if unfolded != s {
+		t.Errorf("unfolded line = %q, want %q", unfolded, s)
+	}
+}
@@ -0,0 +1,126 @@
+package campaigns
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reg/internal/database"
+	email "reg/internal/emails"
+	"reg/internal/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	mu      sync.Mutex
+	running = map[int]bool{}
+)
+
+// Start fixes the recipients of a draft campaign and begins queueing its
+// emails in the background. It returns the number of recipients, or
+// email.ErrUnsubscribeUnavailable when the emails could not carry an
+// unsubscribe link.
+func Start(id int) (int, error) {
+	if err := email.CheckUnsubscribe(); err != nil {
+		return 0, err
+	}
+	n, err := database.StartCampaign(context.Background(), id)
+	if err != nil {
+		return 0, err
+	}
+	launch(id)
+	return n, nil
+}
+
+// Resume carries on sending the campaigns a previous process was sending
+// when it stopped.
+func Resume() {
+	ids, err := database.GetSendingCampaigns(context.Background())
+	if err != nil {
+		log.Printf("Failed to resume campaigns: %v", err)
+		return
+	}
+	for _, id := range ids {
+		launch(id)
+	}
+}
+
+func launch(id int) {
+	mu.Lock()
+	defer mu.Unlock()
+	if running[id] {
+		return
+	}
+	running[id] = true
+	go run(id)
+}
+
+// Paragraphs splits a campaign message on blank lines.
+func Paragraphs(message string) []string {
+	var paragraphs []string
+	for _, p := range strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	return paragraphs
+}
+
+// Data fills a campaign template for one recipient.
+func Data(c *model.Campaign, name string) email.CampaignData {
+	return email.CampaignData{
+		Name:       name,
+		Heading:    c.Heading,
+		Paragraphs: Paragraphs(c.Message),
+		ActionText: c.ActionText,
+		ActionURL:  c.ActionURL,
+	}
+}
+
+func run(id int) {
+	defer func() {
+		mu.Lock()
+		delete(running, id)
+		mu.Unlock()
+	}()
+
+	ctx := context.Background()
+	c, err := database.GetCampaign(ctx, id)
+	if err != nil {
+		log.Printf("Campaign %d stopped: %v", id, err)
+		return
+	}
+	pause := time.Minute / time.Duration(max(c.RatePerMinute, 1))
+	queued, failed := 0, 0
+
+	log.Printf("Campaign %d started", id)
+	for {
+		// Fetched one at a time so a cancelled campaign stops at once
+		r, err := database.NextCampaignRecipient(ctx, id)
+		if err != nil {
+			log.Printf("Campaign %d stopped: %v", id, err)
+			return
+		}
+		if r == nil {
+			break
+		}
+
+		if _, err := email.QueueCampaignEmail(id, r.UserID, r.Email, c.Subject, c.Template, c.Category, Data(c, r.Name)); err != nil {
+			fmt.Printf("Failed to queue campaign %d email to %s, ERR: %s\n", id, r.Email, err)
+			failed++
+			if err := database.FailCampaignRecipient(ctx, id, r.UserID, err); err != nil {
+				log.Printf("Campaign %d stopped: %v", id, err)
+				return
+			}
+		} else {
+			queued++
+		}
+		time.Sleep(pause)
+	}
+
+	if err := database.FinishCampaign(ctx, id); err != nil {
+		fmt.Println(err)
+	}
+	log.Printf("Campaign %d finished: %d queued, %d failed", id, queued, failed)
+}
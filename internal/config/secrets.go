@@ -0,0 +1,26 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"os"
+)
+
+// SigningKey returns the key a feature signs its tokens with. The dedicated
+// env variable should be set in production; without it the key is derived
+// from SECRET_KEY for that purpose, so that a signature made for one
+// feature is never valid for another or for a session token.
+func SigningKey(env, purpose string) ([]byte, error) {
+	if key := os.Getenv(env); key != "" {
+		return []byte(key), nil
+	}
+
+	secret := os.Getenv("SECRET_KEY")
+	if secret == "" {
+		return nil, fmt.Errorf("%s is not configured", env)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil), nil
+}
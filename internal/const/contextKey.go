@@ -3,6 +3,8 @@ package constants
 type contextKey string
 
 const (
-	UserIDKey contextKey = "userID"
-	EmailKey  contextKey = "email"
+	UserIDKey  contextKey = "userID"
+	EmailKey   contextKey = "email"
+	AdminKey   contextKey = "admin"
+	ScannerKey contextKey = "scanner"
 )
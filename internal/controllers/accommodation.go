@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	constants "reg/internal/const"
+	"reg/internal/database"
+	"reg/internal/model"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GenderRequest struct {
+	Gender string `json:"gender"`
+}
+
+type HostelRequest struct {
+	Name   string `json:"name"`
+	Gender string `json:"gender"`
+}
+
+type RoomsRequest struct {
+	Rooms []model.Room `json:"rooms"`
+}
+
+type AssignRoomRequest struct {
+	RoomID int `json:"room_id"`
+}
+
+// DeskRequest identifies a guest at the hostel desk by scanning their pass
+// or by ticket id.
+type DeskRequest struct {
+	Code     string `json:"code"`
+	TicketID int    `json:"ticket_id"`
+}
+
+func accommodationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrHostelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Hostel not found"})
+	case errors.Is(err, database.ErrRoomNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+	case errors.Is(err, database.ErrAllocationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "No room allocated"})
+	case errors.Is(err, database.ErrNoAccommodation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ticket does not include accommodation"})
+	case errors.Is(err, database.ErrGenderMismatch):
+		c.JSON(http.StatusConflict, gin.H{"error": "Hostel does not accommodate the holder's gender"})
+	case errors.Is(err, database.ErrRoomFull):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+	}
+}
+
+// SetGenderHandler records the signed-in user's gender for room allocation.
+func SetGenderHandler(c *gin.Context) {
+	userID, ok := sessionUserID(c)
+	if !ok {
+		return
+	}
+
+	var req GenderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	req.Gender = strings.ToLower(strings.TrimSpace(req.Gender))
+	if !database.ValidGender(req.Gender) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gender must be male, female or other"})
+		return
+	}
+
+	if err := database.SetUserGender(context.Background(), userID, req.Gender); err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Gender updated", "gender": req.Gender})
+}
+
+// GetHostelsHandler lists hostels with their rooms and occupancy.
+func GetHostelsHandler(c *gin.Context) {
+	hostels, err := database.GetHostels(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"hostels": hostels})
+}
+
+// CreateHostelHandler adds a hostel for male, female or any guests.
+func CreateHostelHandler(c *gin.Context) {
+	var req HostelRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	req.Gender = strings.ToLower(strings.TrimSpace(req.Gender))
+	if req.Gender == "" {
+		req.Gender = database.GenderAny
+	}
+	if req.Gender != database.GenderMale && req.Gender != database.GenderFemale && req.Gender != database.GenderAny {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gender must be male, female or any"})
+		return
+	}
+
+	hostel, err := database.CreateHostel(context.Background(), strings.TrimSpace(req.Name), req.Gender)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			c.JSON(http.StatusConflict, gin.H{"error": "A hostel with this name already exists"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Hostel created", "hostel": hostel})
+}
+
+// SetRoomsHandler adds rooms to a hostel or changes their capacity.
+func SetRoomsHandler(c *gin.Context) {
+	hostelID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hostel id"})
+		return
+	}
+
+	var req RoomsRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Rooms) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	for i := range req.Rooms {
+		req.Rooms[i].Number = strings.TrimSpace(req.Rooms[i].Number)
+		if req.Rooms[i].Number == "" || req.Rooms[i].Capacity <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid room at index %d", i)})
+			return
+		}
+	}
+
+	if err := database.SetRooms(context.Background(), hostelID, req.Rooms); err != nil {
+		accommodationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Rooms saved", "rooms": len(req.Rooms)})
+}
+
+// GetAllocationsHandler lists room allocations, of one hostel when
+// hostel_id is given.
+func GetAllocationsHandler(c *gin.Context) {
+	hostelID := 0
+	if id := c.Query("hostel_id"); id != "" {
+		var err error
+		if hostelID, err = strconv.Atoi(id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hostel id"})
+			return
+		}
+	}
+
+	allocations, err := database.GetAllocations(context.Background(), hostelID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"allocations": allocations})
+}
+
+// AllocateRoomsHandler gives a room to every accommodation holder without
+// one.
+func AllocateRoomsHandler(c *gin.Context) {
+	allocated, unallocated, err := database.AllocateRooms(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Rooms allocated", "allocated": allocated, "unallocated": unallocated})
+}
+
+// AssignRoomHandler puts an accommodation holder in a room chosen by an
+// admin.
+func AssignRoomHandler(c *gin.Context) {
+	admin, _ := c.Request.Context().Value(constants.AdminKey).(string)
+
+	ticketID, err := strconv.Atoi(c.Param("ticket_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket id"})
+		return
+	}
+	var req AssignRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RoomID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	allocation, err := database.AssignRoom(context.Background(), ticketID, req.RoomID, admin)
+	if err != nil {
+		accommodationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Room assigned", "allocation": allocation})
+}
+
+// ReleaseRoomHandler frees the room allocated to a ticket.
+func ReleaseRoomHandler(c *gin.Context) {
+	ticketID, err := strconv.Atoi(c.Param("ticket_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket id"})
+		return
+	}
+
+	if err := database.ReleaseRoom(context.Background(), ticketID); err != nil {
+		accommodationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Room released"})
+}
+
+// HostelCheckInHandler checks a guest into their room at the hostel desk.
+func HostelCheckInHandler(c *gin.Context) {
+	hostelDesk(c, database.HostelCheckIn, "Checked in")
+}
+
+// HostelCheckOutHandler checks a guest out at the hostel desk.
+func HostelCheckOutHandler(c *gin.Context) {
+	hostelDesk(c, database.HostelCheckOut, "Checked out")
+}
+
+// Why a guest cannot be moved on from their current state at the desk
+var deskStates = map[string]string{
+	database.AllocationAllocated:  "Guest has not checked in yet",
+	database.AllocationCheckedIn:  "Guest is already checked in",
+	database.AllocationCheckedOut: "Guest has already checked out",
+}
+
+func hostelDesk(c *gin.Context, move func(context.Context, int) (*model.RoomAllocation, error), message string) {
+	var req DeskRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "" && req.TicketID <= 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	ticketID := req.TicketID
+	if req.Code != "" {
+		ticket, err := database.VerifyPassCode(context.Background(), strings.TrimSpace(req.Code))
+		if err != nil {
+			if errors.Is(err, database.ErrInvalidPass) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Invalid pass"})
+				return
+			}
+			fmt.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+			return
+		}
+		ticketID = ticket.TicketID
+	}
+
+	allocation, err := move(context.Background(), ticketID)
+	if err != nil {
+		if errors.Is(err, database.ErrAllocationState) {
+			c.JSON(http.StatusConflict, gin.H{"error": deskStates[allocation.Status], "allocation": allocation})
+			return
+		}
+		accommodationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "allocation": allocation})
+}
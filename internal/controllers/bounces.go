@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reg/internal/bounces"
+	"reg/internal/database"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBouncesHandler lists the users whose email address bounced, with their
+// phone numbers for the help desk.
+func GetBouncesHandler(c *gin.Context) {
+	users, err := database.GetUndeliverableUsers(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users})
+}
+
+// ScanBouncesHandler reads the bounce mailbox now instead of waiting for
+// the next scan.
+func ScanBouncesHandler(c *gin.Context) {
+	if !bounces.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Bounce mailbox is not configured"})
+		return
+	}
+
+	result, err := bounces.Scan()
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bounce mailbox scanned", "result": result})
+}
+
+// ClearBounceHandler marks a user's email address as deliverable again.
+func ClearBounceHandler(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return
+	}
+
+	if err := database.ClearUndeliverable(context.Background(), userID); err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No bounced address for this user"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email address marked deliverable"})
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reg/internal/calendar"
+	"reg/internal/database"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveCalendar writes the calendar of a tier as a .ics download, of the
+// event days alone when tier is empty.
+func serveCalendar(c *gin.Context, tier string) {
+	events, err := calendar.ForTier(context.Background(), tier)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="esummit-2025.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", calendar.ICS(events))
+}
+
+// GetCalendarHandler serves the event calendar, with the sessions of a tier
+// when ?tier= is given.
+func GetCalendarHandler(c *gin.Context) {
+	serveCalendar(c, strings.TrimSpace(c.Query("tier")))
+}
+
+// GetMyCalendarHandler serves the calendar of the signed-in user's pass,
+// the event days alone when they have none.
+func GetMyCalendarHandler(c *gin.Context) {
+	userID, ok := sessionUserID(c)
+	if !ok {
+		return
+	}
+
+	tier := ""
+	ticket, err := database.GetUserPurchasedTicket(context.Background(), userID)
+	switch {
+	case err == nil:
+		tier = ticket.TicketTitle
+	case !errors.Is(err, database.ErrTicketNotFound):
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	serveCalendar(c, tier)
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reg/internal/campaigns"
+	constants "reg/internal/const"
+	"reg/internal/database"
+	emails "reg/internal/emails"
+	"reg/internal/model"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CampaignRequest struct {
+	Name          string                `json:"name"`
+	Template      string                `json:"template"`
+	Subject       string                `json:"subject"`
+	Heading       string                `json:"heading"`
+	Message       string                `json:"message"`
+	ActionText    string                `json:"action_text"`
+	ActionURL     string                `json:"action_url"`
+	Segment       model.CampaignSegment `json:"segment"`
+	Category      string                `json:"category"`
+	RatePerMinute int                   `json:"rate_per_minute"`
+}
+
+// Emails queued per minute when a campaign does not set a rate
+const defaultCampaignRate = 60
+
+func campaignError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrCampaignNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+	case errors.Is(err, database.ErrCampaignState):
+		c.JSON(http.StatusConflict, gin.H{"error": "Campaign cannot be changed in its current state"})
+	case errors.Is(err, emails.ErrUnsubscribeUnavailable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Campaigns cannot be sent until unsubscribe links are configured (PUBLIC_API_URL)"})
+	default:
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+	}
+}
+
+// validate trims a campaign request and returns why it is invalid, empty
+// when it is not.
+func (req *CampaignRequest) validate() string {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Subject = strings.TrimSpace(req.Subject)
+	req.Heading = strings.TrimSpace(req.Heading)
+	req.ActionText = strings.TrimSpace(req.ActionText)
+	req.ActionURL = strings.TrimSpace(req.ActionURL)
+
+	switch {
+	case req.Name == "":
+		return "Missing campaign name"
+	case req.Subject == "":
+		return "Missing subject"
+	case !slices.Contains(emails.CampaignTemplates, req.Template):
+		return "Template must be one of " + strings.Join(emails.CampaignTemplates, ", ")
+	case (req.ActionURL == "") != (req.ActionText == ""):
+		return "action_text and action_url go together"
+	case req.Category != "" && !database.OptionalEmailCategory(req.Category):
+		return "category must be announcements or reminders"
+	case req.RatePerMinute < 0 || req.RatePerMinute > 1000:
+		return "rate_per_minute must be between 1 and 1000, or 0 for the default"
+	}
+	if req.ActionURL != "" {
+		if u, err := url.Parse(req.ActionURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return "Invalid action_url"
+		}
+	}
+	if req.Heading == "" {
+		req.Heading = req.Subject
+	}
+	if req.Category == "" {
+		req.Category = database.EmailAnnouncements
+	}
+	if req.RatePerMinute == 0 {
+		req.RatePerMinute = defaultCampaignRate
+	}
+	return ""
+}
+
+func campaignID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid campaign id"})
+		return 0, false
+	}
+	return id, true
+}
+
+// PreviewCampaignHandler counts the users a campaign would be sent to, and
+// those of its segment who opted out of its category, and renders its email
+// for the first of them.
+func PreviewCampaignHandler(c *gin.Context) {
+	var req CampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	count, optedOut, sample, err := database.PreviewSegment(context.Background(), req.Segment, req.Category, 10)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	name, userID := "Participant", 0
+	if len(sample) > 0 {
+		name, userID = sample[0].Name, sample[0].UserID
+	}
+	campaign := model.Campaign{Heading: req.Heading, Message: req.Message, ActionText: req.ActionText, ActionURL: req.ActionURL}
+	data := campaigns.Data(&campaign, name)
+	data.UnsubscribeURL = emails.UnsubscribeURL(userID, req.Category)
+	body, err := emails.RenderCampaignEmail(req.Template, data)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recipients": count, "opted_out": optedOut, "sample": sample, "html": string(body)})
+}
+
+// CreateCampaignHandler saves a campaign as a draft to be sent later.
+func CreateCampaignHandler(c *gin.Context) {
+	admin, _ := c.Request.Context().Value(constants.AdminKey).(string)
+
+	var req CampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	campaign, err := database.CreateCampaign(context.Background(), model.Campaign{
+		Name:          req.Name,
+		Template:      req.Template,
+		Subject:       req.Subject,
+		Heading:       req.Heading,
+		Message:       req.Message,
+		ActionText:    req.ActionText,
+		ActionURL:     req.ActionURL,
+		Segment:       req.Segment,
+		Category:      req.Category,
+		RatePerMinute: req.RatePerMinute,
+		CreatedBy:     admin,
+	})
+	if err != nil {
+		campaignError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Campaign created", "campaign": campaign})
+}
+
+// GetCampaignsHandler lists campaigns, newest first.
+func GetCampaignsHandler(c *gin.Context) {
+	list, err := database.GetCampaigns(context.Background())
+	if err != nil {
+		campaignError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"campaigns": list})
+}
+
+// GetCampaignHandler returns a campaign with its recipients counted by
+// status.
+func GetCampaignHandler(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+
+	campaign, err := database.GetCampaign(context.Background(), id)
+	if err != nil {
+		campaignError(c, err)
+		return
+	}
+	summary, err := database.GetCampaignSummary(context.Background(), id)
+	if err != nil {
+		campaignError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"campaign": campaign, "recipients": summary})
+}
+
+// GetCampaignRecipientsHandler lists the recipients of a campaign with the
+// status of their email, of one status when ?status= is given.
+func GetCampaignRecipientsHandler(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+	status := c.Query("status")
+	switch status {
+	case "", database.RecipientPending, database.RecipientQueued, database.RecipientSent, database.RecipientFailed, database.RecipientCancelled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 1000 || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit or offset"})
+		return
+	}
+
+	if _, err := database.GetCampaign(context.Background(), id); err != nil {
+		campaignError(c, err)
+		return
+	}
+	recipients, err := database.GetCampaignRecipients(context.Background(), id, status, limit, offset)
+	if err != nil {
+		campaignError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recipients": recipients})
+}
+
+// SendCampaignHandler starts sending a draft campaign to its segment.
+func SendCampaignHandler(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+
+	n, err := campaigns.Start(id)
+	if err != nil {
+		campaignError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Campaign sending started", "recipients": n})
+}
+
+// CancelCampaignHandler stops a campaign. Emails already queued are still
+// delivered.
+func CancelCampaignHandler(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+
+	if err := database.CancelCampaign(context.Background(), id); err != nil {
+		campaignError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Campaign cancelled"})
+}
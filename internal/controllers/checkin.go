@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	constants "reg/internal/const"
+	"reg/internal/database"
+	"reg/internal/model"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Longest code accepted from a scanner, real pass codes are far shorter
+const maxScannedCodeLength = 256
+
+type CheckInRequest struct {
+	Code string `json:"code"`
+}
+
+type ScannerRequest struct {
+	Name string `json:"name"`
+	Gate string `json:"gate"`
+	Zone string `json:"zone"`
+}
+
+type EntitlementRequest struct {
+	Title   string `json:"title"`
+	Kind    string `json:"kind"`
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	MaxUses *int   `json:"max_uses"`
+}
+
+// CheckInHandler admits the holder of a scanned pass at the scanner's gate or
+// zone.
+func CheckInHandler(c *gin.Context) {
+	scanner, ok := c.Request.Context().Value(constants.ScannerKey).(model.Scanner)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: scanner token required"})
+		return
+	}
+
+	var req CheckInRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Code) == "" || len(req.Code) > maxScannedCodeLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	checkIn, err := database.CheckIn(context.Background(), strings.TrimSpace(req.Code), scanner)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	checkIns.publish(*checkIn)
+	c.JSON(checkInStatus(checkIn.Result), checkIn)
+}
+
+func checkInStatus(result string) int {
+	switch result {
+	case database.CheckInAdmitted:
+		return http.StatusOK
+	case database.CheckInAlreadyUsed:
+		return http.StatusConflict
+	case database.CheckInRevoked, database.CheckInDenied:
+		return http.StatusForbidden
+	default:
+		return http.StatusNotFound
+	}
+}
+
+// CreateScannerHandler registers a scanner for a gate, or for a zone when
+// one is given. The token is only returned here.
+func CreateScannerHandler(c *gin.Context) {
+	var req ScannerRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" || strings.TrimSpace(req.Gate) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	admin, _ := c.Request.Context().Value(constants.AdminKey).(string)
+	scanner, token, err := database.CreateScanner(context.Background(), strings.TrimSpace(req.Name), strings.TrimSpace(req.Gate), strings.TrimSpace(req.Zone), admin)
+	if err != nil {
+		if errors.Is(err, database.ErrEntitlementNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown zone"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create scanner"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scanner created successfully", "scanner": scanner, "token": token})
+}
+
+func GetScannersHandler(c *gin.Context) {
+	scanners, err := database.GetScanners(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"scanners": scanners})
+}
+
+// DeactivateScannerHandler revokes a scanner's token.
+func DeactivateScannerHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scanner id"})
+		return
+	}
+
+	if err := database.SetScannerActive(context.Background(), id, false); err != nil {
+		if errors.Is(err, database.ErrScannerNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Scanner not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scanner deactivated successfully"})
+}
+
+func GetEntitlementsHandler(c *gin.Context) {
+	entitlements, err := database.GetEntitlements(context.Background(), c.Query("title"))
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entitlements": entitlements})
+}
+
+// SetEntitlementHandler adds an entitlement to a tier or updates it. Meals
+// and nights are single use unless max_uses says otherwise.
+func SetEntitlementHandler(c *gin.Context) {
+	var req EntitlementRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Title == "" || req.Code == "" || req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	switch req.Kind {
+	case "zone", "session":
+	case "meal", "night":
+		if req.MaxUses == nil {
+			once := 1
+			req.MaxUses = &once
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kind must be zone, session, meal or night"})
+		return
+	}
+	if req.MaxUses != nil && *req.MaxUses <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_uses must be positive"})
+		return
+	}
+
+	err := database.SetEntitlement(context.Background(), model.Entitlement{
+		TicketTitle: req.Title,
+		Kind:        req.Kind,
+		Code:        strings.ToLower(strings.TrimSpace(req.Code)),
+		Name:        req.Name,
+		MaxUses:     req.MaxUses,
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrTicketNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown ticket type"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Entitlement saved successfully"})
+}
+
+func DeleteEntitlementHandler(c *gin.Context) {
+	title, code := c.Query("title"), c.Query("code")
+	if title == "" || code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing title or code"})
+		return
+	}
+
+	if err := database.DeleteEntitlement(context.Background(), title, code); err != nil {
+		if errors.Is(err, database.ErrEntitlementNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Entitlement not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Entitlement deleted successfully"})
+}
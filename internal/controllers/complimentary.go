@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	constants "reg/internal/const"
+	"reg/internal/database"
+	"reg/internal/dispatch"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type compResult struct {
+	Row      int    `json:"row"`
+	Email    string `json:"email"`
+	Status   string `json:"status"`
+	TicketID int    `json:"ticket_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// IssueComplimentaryPasses issues free passes from a CSV with name, email,
+// tier and reason columns, sent either as a "file" form field or as the
+// request body. Passes respect the capacity of their tier unless the
+// override_capacity query parameter is set.
+func IssueComplimentaryPasses(c *gin.Context) {
+	admin, _ := c.Request.Context().Value(constants.AdminKey).(string)
+
+	overCapacity := false
+	if value := c.Query("override_capacity"); value != "" {
+		var err error
+		if overCapacity, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid override_capacity"})
+			return
+		}
+	}
+
+	var source io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			fmt.Println(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV file"})
+			return
+		}
+		defer f.Close()
+		source = f
+	}
+
+	reader := csv.NewReader(source)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil || len(records) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV: expected a header row and at least one entry"})
+		return
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"name", "email", "tier", "reason"} {
+		if _, ok := columns[required]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV: missing column " + required})
+			return
+		}
+	}
+
+	catalog, err := database.GetCatalog(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	tiers := make(map[string]string)
+	for _, t := range catalog {
+		tiers[strings.ToUpper(t.Title)] = t.Title
+	}
+
+	field := func(record []string, column string) string {
+		if i := columns[column]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	results := make([]compResult, 0, len(records)-1)
+	var issued []int
+	for i, record := range records[1:] {
+		res := compResult{Row: i + 2, Email: strings.ToLower(field(record, "email"))}
+		name, reason := field(record, "name"), field(record, "reason")
+		tier, ok := tiers[strings.ToUpper(field(record, "tier"))]
+
+		switch {
+		case name == "":
+			res.Status, res.Error = "error", "missing name"
+		case res.Email == "":
+			res.Status, res.Error = "error", "missing email"
+		case !ok:
+			res.Status, res.Error = "error", "unknown tier"
+		case reason == "":
+			res.Status, res.Error = "error", "missing reason"
+		}
+		if res.Status == "" {
+			if _, err := mail.ParseAddress(res.Email); err != nil {
+				res.Status, res.Error = "error", "invalid email"
+			}
+		}
+		if res.Status != "" {
+			results = append(results, res)
+			continue
+		}
+
+		ticketID, err := database.IssueComplimentaryTicket(context.Background(), res.Email, name, tier, reason, admin, overCapacity)
+		switch {
+		case errors.Is(err, database.ErrAlreadyHasTicket):
+			res.Status, res.Error = "skipped", err.Error()
+		case errors.Is(err, database.ErrSoldOut):
+			res.Status, res.Error = "error", "tier is sold out"
+		case err != nil:
+			fmt.Println(err)
+			res.Status, res.Error = "error", "failed to issue ticket"
+		default:
+			res.Status, res.TicketID = "issued", ticketID
+			issued = append(issued, ticketID)
+		}
+		results = append(results, res)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Complimentary passes processed", "issued": len(issued), "results": results})
+
+	// Send the passes
+	for _, ticketID := range issued {
+		ticket, err := database.GetUserTicket(context.Background(), ticketID)
+		if err != nil {
+			fmt.Println(err)
+			fmt.Println("TAKE ACTION>>>>>>>>>>>>>>>>>>> FOR TICKET: ", ticketID)
+			continue
+		}
+		if ok, err := dispatch.SendPass(*ticket); !ok {
+			fmt.Printf("Failed to send email to %s, ERR: %s\n", ticket.Email, err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	constants "reg/internal/const"
+	"reg/internal/cookies"
+	"reg/internal/database"
+	"reg/internal/model"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// How often streams push fresh counts, this also keeps idle
+	// connections open through proxies
+	countsInterval = 10 * time.Second
+
+	// Check-ins buffered per dashboard before it is considered too slow
+	// and events are dropped for it
+	streamBuffer = 64
+
+	defaultRecentCheckIns = 20
+	maxRecentCheckIns     = 200
+)
+
+// checkInHub fans check-ins out to the connected dashboards.
+type checkInHub struct {
+	mu          sync.Mutex
+	subscribers map[chan model.CheckIn]struct{}
+}
+
+var checkIns = &checkInHub{subscribers: make(map[chan model.CheckIn]struct{})}
+
+func (h *checkInHub) subscribe() chan model.CheckIn {
+	ch := make(chan model.CheckIn, streamBuffer)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *checkInHub) unsubscribe(ch chan model.CheckIn) {
+	h.mu.Lock()
+	delete(h.subscribers, ch)
+	h.mu.Unlock()
+}
+
+// publish never blocks, a dashboard that falls behind misses events and
+// catches up with the next counts.
+func (h *checkInHub) publish(checkIn model.CheckIn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- checkIn:
+		default:
+		}
+	}
+}
+
+// CheckInStreamTicketHandler issues the ticket a dashboard passes as the
+// ticket query parameter of the check-in stream, since an EventSource cannot
+// send the admin token in a header.
+func CheckInStreamTicketHandler(c *gin.Context) {
+	admin, _ := c.Request.Context().Value(constants.AdminKey).(string)
+
+	ticket, expiresAt, err := cookies.GenerateStreamTicket(admin)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ticket": ticket, "expires_at": expiresAt.UTC().Format(time.RFC3339)})
+}
+
+// CheckInStreamHandler streams check-ins to an admin dashboard as
+// Server-Sent Events: "checkin" for every scan and "counts" with the
+// totals by gate and tier every few seconds.
+func CheckInStreamHandler(c *gin.Context) {
+	events := checkIns.subscribe()
+	defer checkIns.unsubscribe(events)
+
+	ticker := time.NewTicker(countsInterval)
+	defer ticker.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	sendCounts := func() bool {
+		counts, err := database.GetCheckInCounts(context.Background())
+		if err != nil {
+			fmt.Println(err)
+			return false
+		}
+		c.SSEvent("counts", counts)
+		return true
+	}
+	if !sendCounts() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case checkIn := <-events:
+			c.SSEvent("checkin", checkIn)
+			return true
+		case <-ticker.C:
+			return sendCounts()
+		}
+	})
+}
+
+// CheckInSnapshotHandler returns the current counts and latest scans so a
+// dashboard can rebuild its state after reconnecting.
+func CheckInSnapshotHandler(c *gin.Context) {
+	recent := defaultRecentCheckIns
+	if n, err := strconv.Atoi(c.Query("recent")); err == nil && n >= 0 {
+		recent = min(n, maxRecentCheckIns)
+	}
+
+	snapshot, err := database.GetCheckInSnapshot(context.Background(), recent)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
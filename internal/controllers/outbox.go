@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reg/internal/database"
+	emails "reg/internal/emails"
+	"reg/internal/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// outboxTime reads a date (2025-02-08, a UTC day like the times stored) or
+// an RFC 3339 time of an outbox search. A date given as the end of a range
+// includes that day.
+func outboxTime(value string, end bool) (string, bool) {
+	const layout = "2006-01-02 15:04:05"
+	if value == "" {
+		return "", true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC().Format(layout), true
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return "", false
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t.Format(layout), true
+}
+
+// GetOutboxHandler searches the emails of the outbox, newest first, with a
+// count of every status. ?recipient= matches part of an address, ?template=,
+// ?status= and ?user_id= match exactly, and ?since= and ?until= take a date
+// or an RFC 3339 time.
+func GetOutboxHandler(c *gin.Context) {
+	filter := model.OutboxFilter{
+		Recipient: strings.TrimSpace(c.Query("recipient")),
+		Template:  c.Query("template"),
+		Status:    c.Query("status"),
+		Limit:     100,
+	}
+	switch filter.Status {
+	case "", database.OutboxPending, database.OutboxSending, database.OutboxSent, database.OutboxDead:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	if c.Query("limit") != "" {
+		n, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		filter.Limit = min(n, 1000)
+	}
+	if c.Query("offset") != "" {
+		n, err := strconv.Atoi(c.Query("offset"))
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+			return
+		}
+		filter.Offset = n
+	}
+	if c.Query("user_id") != "" {
+		n, err := strconv.Atoi(c.Query("user_id"))
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			return
+		}
+		filter.UserID = n
+	}
+	var ok bool
+	if filter.Since, ok = outboxTime(c.Query("since"), false); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since, use YYYY-MM-DD or RFC 3339"})
+		return
+	}
+	if filter.Until, ok = outboxTime(c.Query("until"), true); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid until, use YYYY-MM-DD or RFC 3339"})
+		return
+	}
+
+	emails, err := database.GetOutboxEmails(context.Background(), filter)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	summary, err := database.GetOutboxSummary(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"emails": emails, "summary": summary})
+}
+
+// GetOutboxEmailHandler returns an email of the outbox with its full
+// message.
+func GetOutboxEmailHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email id"})
+		return
+	}
+
+	e, err := database.GetOutboxEmail(context.Background(), id)
+	if err != nil {
+		if errors.Is(err, database.ErrOutboxEmailNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Email not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"email": e, "message": string(e.Message)})
+}
+
+// RetryOutboxEmailHandler sends a dead email again.
+func RetryOutboxEmailHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email id"})
+		return
+	}
+
+	if err := database.RetryOutboxEmail(context.Background(), id); err != nil {
+		switch {
+		case errors.Is(err, database.ErrOutboxEmailNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Email not found"})
+		case errors.Is(err, database.ErrOutboxEmailNotDead):
+			c.JSON(http.StatusConflict, gin.H{"error": "Only dead emails can be retried"})
+		default:
+			fmt.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email queued again"})
+}
+
+// RetryDeadEmailsHandler sends every dead email again.
+func RetryDeadEmailsHandler(c *gin.Context) {
+	n, err := database.RetryDeadEmails(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Emails queued again", "retried": n})
+}
+
+// ResendOutboxEmailHandler sends a copy of a delivered or dead email, for
+// someone who says they never got it.
+func ResendOutboxEmailHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email id"})
+		return
+	}
+
+	resentID, err := emails.Resend(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrOutboxEmailNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Email not found"})
+		case errors.Is(err, database.ErrOutboxEmailPending):
+			c.JSON(http.StatusConflict, gin.H{"error": "Email is still being delivered"})
+		case errors.Is(err, emails.ErrPassNotLinked):
+			c.JSON(http.StatusConflict, gin.H{"error": "Pass email is not linked to a ticket, send the pass with POST /admin/passes/dispatch"})
+		case errors.Is(err, emails.ErrPassNotActive), errors.Is(err, database.ErrTicketNotFound):
+			c.JSON(http.StatusConflict, gin.H{"error": "Ticket is no longer active, its pass cannot be sent"})
+		default:
+			fmt.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Email queued again", "id": resentID})
+}
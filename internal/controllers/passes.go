@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	constants "reg/internal/const"
+	"reg/internal/database"
+	"reg/internal/passes"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyPassHandler checks a pass code and returns the ticket it belongs to.
+func VerifyPassHandler(c *gin.Context) {
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing pass code"})
+		return
+	}
+
+	ticket, err := database.VerifyPassCode(context.Background(), code)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidPass) {
+			c.JSON(http.StatusNotFound, gin.H{"valid": false, "error": "Invalid pass"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":    ticket.Status == "active",
+		"ticketId": ticket.TicketID,
+		"name":     ticket.Name,
+		"email":    ticket.Email,
+		"ticket":   ticket.TicketTitle,
+		"status":   ticket.Status,
+	})
+}
+
+// GetPassPNGHandler serves the signed-in user's pass as a PNG image.
+func GetPassPNGHandler(c *gin.Context) {
+	servePass(c, "image/png", passes.PNG)
+}
+
+// GetPassSVGHandler serves the signed-in user's pass as an SVG image.
+func GetPassSVGHandler(c *gin.Context) {
+	servePass(c, "image/svg+xml", passes.SVG)
+}
+
+func servePass(c *gin.Context, contentType string, render func(string) ([]byte, error)) {
+	userid, ok := c.Request.Context().Value(constants.UserIDKey).(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: missing session cookie"})
+		return
+	}
+	id, err := strconv.Atoi(userid)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user id"})
+		return
+	}
+
+	purchased, err := database.GetUserPurchasedTicket(context.Background(), id)
+	if err != nil {
+		if errors.Is(err, database.ErrTicketNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No pass found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	ticket, err := database.GetUserTicket(context.Background(), purchased.ID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	image, err := render(ticket.UID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate pass"})
+		return
+	}
+
+	// The pass changes if the ticket is upgraded or transferred
+	c.Header("Cache-Control", "private, no-cache")
+	c.Data(http.StatusOK, contentType, image)
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reg/internal/database"
+	emails "reg/internal/emails"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailPreferencesRequest struct {
+	Transactional *bool `json:"transactional"`
+	Announcements *bool `json:"announcements"`
+	Reminders     *bool `json:"reminders"`
+}
+
+// GetEmailPreferencesHandler returns the categories of email the user
+// receives.
+func GetEmailPreferencesHandler(c *gin.Context) {
+	userID, ok := sessionUserID(c)
+	if !ok {
+		return
+	}
+
+	prefs, err := database.GetEmailPreferences(context.Background(), userID)
+	if err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"preferences": prefs})
+}
+
+// SetEmailPreferencesHandler turns the optional categories of email on or
+// off. Categories left out of the request are unchanged.
+func SetEmailPreferencesHandler(c *gin.Context) {
+	userID, ok := sessionUserID(c)
+	if !ok {
+		return
+	}
+
+	var req EmailPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	if req.Transactional != nil && !*req.Transactional {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transactional emails cannot be turned off"})
+		return
+	}
+
+	prefs, err := database.SetEmailPreferences(context.Background(), userID, req.Announcements, req.Reminders)
+	if err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email preferences updated", "preferences": prefs})
+}
+
+// unsubscribePage writes the unsubscribe page as the response.
+func unsubscribePage(c *gin.Context, data emails.UnsubscribeData) {
+	body, err := emails.RenderUnsubscribePage(data)
+	if err != nil {
+		fmt.Println(err)
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", body)
+}
+
+// unsubscribeToken reads the signed token of an unsubscribe link.
+func unsubscribeToken(c *gin.Context) (int, string, bool) {
+	userID, category, err := emails.ParseUnsubscribeToken(c.Query("token"))
+	if err != nil || !database.OptionalEmailCategory(category) {
+		if err != nil && !errors.Is(err, emails.ErrInvalidUnsubscribeToken) {
+			fmt.Println(err)
+		}
+		c.String(http.StatusBadRequest, "Invalid unsubscribe link")
+		return 0, "", false
+	}
+	return userID, category, true
+}
+
+// UnsubscribePageHandler asks the user to confirm an unsubscribe link. The
+// link is not applied on GET, as mail scanners open links in emails.
+func UnsubscribePageHandler(c *gin.Context) {
+	userID, category, ok := unsubscribeToken(c)
+	if !ok {
+		return
+	}
+
+	user, err := database.GetUserById(context.Background(), int64(userID))
+	if err != nil {
+		fmt.Println(err)
+		c.String(http.StatusNotFound, "Invalid unsubscribe link")
+		return
+	}
+
+	unsubscribePage(c, emails.UnsubscribeData{Email: user.Email, Category: category, Token: c.Query("token")})
+}
+
+// UnsubscribeHandler turns off the category of email of an unsubscribe
+// link. Mail clients supporting one-click unsubscribe (RFC 8058) POST here
+// directly.
+func UnsubscribeHandler(c *gin.Context) {
+	userID, category, ok := unsubscribeToken(c)
+	if !ok {
+		return
+	}
+
+	email, err := database.Unsubscribe(context.Background(), userID, category)
+	if err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			c.String(http.StatusNotFound, "Invalid unsubscribe link")
+			return
+		}
+		fmt.Println(err)
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	unsubscribePage(c, emails.UnsubscribeData{Email: email, Category: category, Done: true})
+}
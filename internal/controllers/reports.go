@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"reg/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListReportsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"reports": database.ReportNames()})
+}
+
+// GetReportHandler returns a sales report as JSON, or as CSV with ?format=csv.
+func GetReportHandler(c *gin.Context) {
+	name := c.Param("name")
+	report, err := database.GetReport(context.Background(), name)
+	if err != nil {
+		if errors.Is(err, database.ErrUnknownReport) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Report not found", "reports": database.ReportNames()})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".csv"))
+		c.Status(http.StatusOK)
+
+		writer := csv.NewWriter(c.Writer)
+		writer.Write(report.Columns)
+		for _, row := range report.Rows {
+			record := make([]string, len(row))
+			for i, v := range row {
+				if v != nil {
+					record[i] = fmt.Sprint(v)
+				}
+			}
+			writer.Write(record)
+		}
+		writer.Flush()
+		return
+	}
+
+	rows := make([]gin.H, 0, len(report.Rows))
+	for _, row := range report.Rows {
+		entry := gin.H{}
+		for i, column := range report.Columns {
+			entry[column] = row[i]
+		}
+		rows = append(rows, entry)
+	}
+	c.JSON(http.StatusOK, gin.H{"report": name, "rows": rows})
+}
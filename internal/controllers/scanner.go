@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	constants "reg/internal/const"
+	"reg/internal/database"
+	"reg/internal/model"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Most scans accepted in one sync request
+const maxSyncBatch = 1000
+
+type SyncRequest struct {
+	Scans []model.OfflineScan `json:"scans"`
+}
+
+// ScannerManifestHandler sends a scanner the passes it can admit offline.
+// The X-Manifest-Signature header holds the hex HMAC-SHA256 of the body,
+// keyed with the hex SHA-256 of the scanner's token.
+func ScannerManifestHandler(c *gin.Context) {
+	scanner, ok := c.Request.Context().Value(constants.ScannerKey).(model.Scanner)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: scanner token required"})
+		return
+	}
+
+	manifest, err := database.GetScannerManifest(context.Background(), scanner)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	mac := hmac.New(sha256.New, []byte(scanner.TokenHash))
+	mac.Write(body)
+	c.Header("X-Manifest-Signature", hex.EncodeToString(mac.Sum(nil)))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// SyncScansHandler uploads scans recorded while the scanner was offline.
+func SyncScansHandler(c *gin.Context) {
+	scanner, ok := c.Request.Context().Value(constants.ScannerKey).(model.Scanner)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: scanner token required"})
+		return
+	}
+
+	var req SyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Scans) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	if len(req.Scans) > maxSyncBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d scans can be synced at once", maxSyncBatch)})
+		return
+	}
+	for i := range req.Scans {
+		req.Scans[i].ClientID = strings.TrimSpace(req.Scans[i].ClientID)
+		req.Scans[i].Code = strings.TrimSpace(req.Scans[i].Code)
+		if req.Scans[i].ClientID == "" || req.Scans[i].Code == "" || len(req.Scans[i].Code) > maxScannedCodeLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid scan at index %d", i)})
+			return
+		}
+	}
+
+	results, err := database.SyncCheckIns(context.Background(), scanner, req.Scans)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	conflicts := 0
+	for _, r := range results {
+		if r.Conflict {
+			conflicts++
+		}
+		if !r.Duplicate {
+			checkIns.publish(*r.CheckIn)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scans synced", "results": results, "conflicts": conflicts})
+}
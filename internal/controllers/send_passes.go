@@ -1,36 +1,90 @@
 package controllers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	constants "reg/internal/const"
 	"reg/internal/database"
-	email "reg/internal/emails"
+	"reg/internal/dispatch"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
+// SendPassesHandler starts a background job mailing every pass that has not
+// been sent yet. Running it again only picks up unsent and failed passes.
 func SendPassesHandler(c *gin.Context) {
-	userTickets := database.GetIDsForPasses()
+	admin, _ := c.Request.Context().Value(constants.AdminKey).(string)
 
-	for _, userTicket := range userTickets {
-		// send email with pass
-		fmt.Println(userTicket)
-		data, err := email.LoadPassEmailTemplate(userTicket.Name, userTicket.TicketTitle, userTicket.UID)
-		if err != nil {
-			// handle the error
-			fmt.Println(err)
-			continue
+	job, err := dispatch.Start(admin)
+	if err != nil {
+		if errors.Is(err, dispatch.ErrJobRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A dispatch job is already running"})
+			return
 		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Pass dispatch started", "job": job})
+}
+
+// MarkPassesSentHandler records passes that were mailed before dispatch was
+// tracked as sent, given the recipients' emails, so dispatch jobs skip them.
+// Tickets whose pass code was backfilled are not marked, the code they were
+// mailed no longer scans.
+func MarkPassesSentHandler(c *gin.Context) {
+	var req struct {
+		Emails []string `json:"emails"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Emails) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
 
-		ok, err := email.SendPASSEmail(userTicket.Email, nil, "Your E-Summit 2025 Pass & Event Schedule Are Here!", data, "", userTicket.UID)
+	marked, err := database.MarkPassesSent(context.Background(), req.Emails)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Passes marked as sent", "marked": marked})
+}
 
-		if !ok {
-			// handle the error
-			fmt.Printf("Failed to send email to %s, ERR: %s\n", userTicket.Email, err)
-			continue
+// GetPassDispatchHandler reports the progress of a dispatch job, the latest
+// one unless ?job= is given, and the send status of every pass.
+func GetPassDispatchHandler(c *gin.Context) {
+	var jobID int64
+	if c.Query("job") != "" {
+		id, err := strconv.ParseInt(c.Query("job"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job id"})
+			return
 		}
+		jobID = id
+	}
 
+	job, err := database.GetDispatchJob(context.Background(), jobID)
+	if err != nil && !(errors.Is(err, database.ErrJobNotFound) && jobID == 0) {
+		if errors.Is(err, database.ErrJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dispatch job not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
 	}
 
-	c.JSON(200, gin.H{"message": "Passes sent successfully"})
+	summary, err := database.GetDispatchSummary(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
 
+	c.JSON(http.StatusOK, gin.H{"job": job, "passes": summary})
 }
@@ -89,6 +89,7 @@ func RegisterUserHandler(c *gin.Context) {
 		Name          string `json:"name"`
 		ContactNumber string `json:"contact_number"`
 		Data          string `json:"data"`
+		Gender        string `json:"gender"`
 		Otp           string `json:"otp"`
 	}
 
@@ -99,6 +100,11 @@ func RegisterUserHandler(c *gin.Context) {
 		return
 	}
 	req.Email = strings.ToLower(req.Email)
+	req.Gender = strings.ToLower(strings.TrimSpace(req.Gender))
+	if req.Gender != "" && !database.ValidGender(req.Gender) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gender must be male, female or other"})
+		return
+	}
 
 	// 2. Check if the user already exists
 	if database.UserExists(req.Email) {
@@ -118,6 +124,7 @@ func RegisterUserHandler(c *gin.Context) {
 		Name:          req.Name,
 		ContactNumber: req.ContactNumber,
 		Data:          req.Data,
+		Gender:        req.Gender,
 	})
 	if err != nil {
 		fmt.Println(err)
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	constants "reg/internal/const"
+	"reg/internal/database"
+	"reg/internal/dispatch"
+	emails "reg/internal/emails"
+	"reg/internal/model"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TransferRequest struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+type AcceptTransferRequest struct {
+	Token string `json:"token"`
+}
+
+// transferAcceptURL is the page of the website where the recipient of a
+// pass accepts it, FRONTEND_URL points at the website.
+func transferAcceptURL(token string) string {
+	base := strings.TrimRight(os.Getenv("FRONTEND_URL"), "/")
+	if base == "" {
+		base = "https://ecell.iith.ac.in/esummit25"
+	}
+	return base + "/transfer?token=" + url.QueryEscape(token)
+}
+
+func transferError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrTicketNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "No pass found"})
+	case errors.Is(err, database.ErrTransferNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+	case errors.Is(err, database.ErrTransferExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "This transfer has expired"})
+	case errors.Is(err, database.ErrTransferPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "A transfer of your pass is already pending, cancel it first"})
+	case errors.Is(err, database.ErrTransferToSelf):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot transfer your pass to yourself"})
+	case errors.Is(err, database.ErrPassCheckedIn):
+		c.JSON(http.StatusConflict, gin.H{"error": "This pass has already been used at the gate"})
+	case errors.Is(err, database.ErrUpgradePending):
+		c.JSON(http.StatusConflict, gin.H{"error": "An upgrade of this pass is awaiting verification"})
+	case errors.Is(err, database.ErrAlreadyHasTicket):
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have a pass"})
+	default:
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+	}
+}
+
+func sessionUserID(c *gin.Context) (int, bool) {
+	userid, ok := c.Request.Context().Value(constants.UserIDKey).(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: missing session cookie"})
+		return 0, false
+	}
+	id, err := strconv.Atoi(userid)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user id"})
+		return 0, false
+	}
+	return id, true
+}
+
+// CreateTransferHandler offers the signed-in user's pass to someone else and
+// emails them a link to accept it.
+func CreateTransferHandler(c *gin.Context) {
+	userID, ok := sessionUserID(c)
+	if !ok {
+		return
+	}
+
+	var req TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing recipient name"})
+		return
+	}
+	if _, err := mail.ParseAddress(req.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipient email"})
+		return
+	}
+
+	transfer, token, err := database.CreatePassTransfer(context.Background(), userID, req.Email, req.Name)
+	if err != nil {
+		transferError(c, err)
+		return
+	}
+
+	data, err := emails.LoadTransferOfferTemplate(transfer.ToName, transfer.FromName, transfer.TicketTitle, transferAcceptURL(token), transfer.ExpiresAt)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println("TAKE ACTION>>>>>>>>>>>>>>>>>>> FOR TRANSFER: ", transfer.ID)
+	} else if ok, err := emails.SendEmail(transfer.ToEmail, nil, transfer.FromName+" Sent You Their Pass | E-Summit 2025", data, ""); !ok {
+		fmt.Printf("Failed to send email to %s, ERR: %s\n", transfer.ToEmail, err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Transfer offered to " + transfer.ToEmail, "transfer": transfer})
+}
+
+// GetTransferHandler returns the signed-in user's pending transfer.
+func GetTransferHandler(c *gin.Context) {
+	userID, ok := sessionUserID(c)
+	if !ok {
+		return
+	}
+
+	transfer, err := database.GetPendingTransfer(context.Background(), userID)
+	if err != nil {
+		if errors.Is(err, database.ErrTransferNotFound) {
+			c.JSON(http.StatusOK, gin.H{"transfer": nil})
+			return
+		}
+		transferError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"transfer": transfer})
+}
+
+// CancelTransferHandler withdraws the signed-in user's pending transfer.
+func CancelTransferHandler(c *gin.Context) {
+	userID, ok := sessionUserID(c)
+	if !ok {
+		return
+	}
+
+	transfer, err := database.CancelPassTransfer(context.Background(), userID)
+	if err != nil {
+		transferError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transfer cancelled", "transfer": transfer})
+}
+
+// GetTransferOfferHandler shows the recipient of a transfer what they are
+// being offered before they accept.
+func GetTransferOfferHandler(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing transfer token"})
+		return
+	}
+
+	transfer, err := database.GetTransferByToken(context.Background(), token)
+	if err != nil {
+		transferError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":       transfer.FromName,
+		"name":       transfer.ToName,
+		"email":      transfer.ToEmail,
+		"ticket":     transfer.TicketTitle,
+		"expires_at": transfer.ExpiresAt,
+	})
+}
+
+// AcceptTransferHandler gives the pass to the recipient holding the token
+// and mails the new pass to them and a notice to the previous holder.
+func AcceptTransferHandler(c *gin.Context) {
+	var req AcceptTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	transfer, err := database.AcceptPassTransfer(context.Background(), req.Token)
+	if err != nil {
+		transferError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pass transferred, it has been emailed to " + transfer.ToEmail, "transfer": transfer})
+
+	ticket, err := database.GetUserTicket(context.Background(), transfer.TicketID)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println("TAKE ACTION>>>>>>>>>>>>>>>>>>> FOR TICKET: ", transfer.TicketID)
+	} else if ok, err := dispatch.SendPass(*ticket); !ok {
+		fmt.Printf("Failed to send email to %s, ERR: %s\n", ticket.Email, err)
+	}
+
+	notifyPreviousHolder(transfer)
+}
+
+func notifyPreviousHolder(transfer *model.PassTransfer) {
+	data, err := emails.LoadTransferCompleteTemplate(transfer.FromName, transfer.ToName, transfer.TicketTitle)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if ok, err := emails.SendEmail(transfer.FromEmail, nil, "Your Pass Has Been Transferred | E-Summit 2025", data, ""); !ok {
+		fmt.Printf("Failed to send email to %s, ERR: %s\n", transfer.FromEmail, err)
+	}
+}
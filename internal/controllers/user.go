@@ -2,10 +2,12 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	constants "reg/internal/const"
 	"reg/internal/database"
+	"reg/internal/model"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -37,10 +39,25 @@ func GetUserHandler(c *gin.Context) {
 		return
 	}
 
+	var links gin.H
+	var room *model.RoomAllocation
+	if ticketId > 0 {
+		links, err = walletLinks(ticketId)
+		if err != nil {
+			fmt.Println(err)
+		}
+		room, err = database.GetTicketAllocation(context.Background(), ticketId)
+		if err != nil && !errors.Is(err, database.ErrAllocationNotFound) {
+			fmt.Println(err)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message":  "User found",
-		"user":     user,
-		"ticketId": ticketId,
+		"message":       "User found",
+		"user":          user,
+		"ticketId":      ticketId,
+		"wallet":        links,
+		"accommodation": room,
 	})
 
 }
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reg/internal/database"
+	"reg/internal/model"
+	"reg/internal/wallet"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AppleWalletHandler downloads the .pkpass of the ticket a pass code
+// belongs to.
+func AppleWalletHandler(c *gin.Context) {
+	ticket, ok := walletTicket(c)
+	if !ok {
+		return
+	}
+
+	p, err := walletPass(ticket)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate pass"})
+		return
+	}
+
+	pass, err := wallet.ApplePass(p)
+	if err != nil {
+		if errors.Is(err, wallet.ErrAppleNotConfigured) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Apple Wallet passes are not available"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate pass"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="esummit25.pkpass"`)
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/vnd.apple.pkpass", pass)
+}
+
+// GoogleWalletHandler redirects to the Google Wallet save page of the ticket
+// a pass code belongs to.
+func GoogleWalletHandler(c *gin.Context) {
+	ticket, ok := walletTicket(c)
+	if !ok {
+		return
+	}
+
+	p, err := walletPass(ticket)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate pass"})
+		return
+	}
+
+	link, err := wallet.GoogleSaveURL(p)
+	if err != nil {
+		if errors.Is(err, wallet.ErrGoogleNotConfigured) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Google Wallet passes are not available"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate pass"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, link)
+}
+
+// walletTicket looks up the ticket of the pass code in the URL, the code
+// itself is the credential for these routes.
+func walletTicket(c *gin.Context) (*model.UserTicket, bool) {
+	ticket, err := database.VerifyPassCode(context.Background(), c.Param("code"))
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidPass) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pass not found"})
+			return nil, false
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return nil, false
+	}
+	if ticket.Status != "active" {
+		c.JSON(http.StatusGone, gin.H{"error": "This pass is no longer valid"})
+		return nil, false
+	}
+	return ticket, true
+}
+
+// walletPass is the wallet pass of a ticket, dated by the event days of the
+// schedule.
+func walletPass(ticket *model.UserTicket) (wallet.Pass, error) {
+	pass := wallet.Pass{
+		TicketID:    ticket.TicketID,
+		Name:        ticket.Name,
+		TicketTitle: ticket.TicketTitle,
+		Code:        ticket.UID,
+	}
+
+	schedule, err := database.GetSchedule(context.Background())
+	if err != nil {
+		return pass, err
+	}
+	for _, e := range schedule {
+		if e.Kind != "day" {
+			continue
+		}
+		if pass.Start.IsZero() || e.Start.Before(pass.Start) {
+			pass.Start = e.Start
+		}
+		if e.End.After(pass.End) {
+			pass.End = e.End
+		}
+	}
+	return pass, nil
+}
+
+// walletLinks returns the wallet links of a ticket for API responses.
+func walletLinks(ticketID int) (gin.H, error) {
+	ticket, err := database.GetUserTicket(context.Background(), ticketID)
+	if err != nil {
+		return nil, err
+	}
+	apple, google := wallet.Links(ticket.UID)
+	return gin.H{"apple": apple, "google": google}, nil
+}
@@ -0,0 +1,67 @@
+package cookies
+
+import (
+	"fmt"
+	"reg/internal/config"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// StreamTicketDuration is how long a stream ticket can be used to open the
+// check-in stream. Dashboards fetch a new one whenever they reconnect.
+const StreamTicketDuration = time.Minute
+
+const streamTicketAudience = "checkin-stream"
+
+func streamTicketKey() ([]byte, error) {
+	return config.SigningKey("STREAM_TICKET_SECRET", streamTicketAudience)
+}
+
+// GenerateStreamTicket returns a short-lived ticket that lets the admin open
+// the check-in stream, for browsers that cannot send headers on an
+// EventSource. It is good for nothing else.
+func GenerateStreamTicket(admin string) (string, time.Time, error) {
+	key, err := streamTicketKey()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expirationTime := time.Now().Add(StreamTicketDuration)
+	claims := &jwt.StandardClaims{
+		Subject:   admin,
+		Audience:  streamTicketAudience,
+		ExpiresAt: expirationTime.Unix(),
+	}
+
+	ticket, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return ticket, expirationTime, nil
+}
+
+// ParseStreamTicket checks a stream ticket and returns the admin it was
+// issued to.
+func ParseStreamTicket(ticket string) (string, error) {
+	key, err := streamTicketKey()
+	if err != nil {
+		return "", err
+	}
+
+	claims := &jwt.StandardClaims{}
+	token, err := jwt.ParseWithClaims(ticket, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return key, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !token.Valid || !claims.VerifyAudience(streamTicketAudience, true) || claims.Subject == "" {
+		return "", fmt.Errorf("invalid stream ticket")
+	}
+	return claims.Subject, nil
+}
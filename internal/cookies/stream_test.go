@@ -0,0 +1,28 @@
+package cookies
+
+import "testing"
+
+func TestStreamTicket(t *testing.T) {
+	t.Setenv("SECRET_KEY", "test-secret")
+
+	ticket, _, err := GenerateStreamTicket("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := ParseStreamTicket(ticket)
+	if err != nil {
+		t.Fatalf("ParseStreamTicket returned error: %v", err)
+	}
+	if admin != "alice" {
+		t.Errorf("ParseStreamTicket = %q, want alice", admin)
+	}
+
+	// Session tokens are signed with another key and are not stream tickets
+	session, err := GenerateToken(1, "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseStreamTicket(session); err == nil {
+		t.Error("ParseStreamTicket accepted a session token")
+	}
+}
@@ -0,0 +1,475 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"reg/internal/model"
+	"time"
+)
+
+// Genders users can give, hostels take one of the first two or anyone
+const (
+	GenderMale   = "male"
+	GenderFemale = "female"
+	GenderOther  = "other"
+	GenderAny    = "any"
+)
+
+// Room allocation statuses
+const (
+	AllocationAllocated  = "allocated"
+	AllocationCheckedIn  = "checked_in"
+	AllocationCheckedOut = "checked_out"
+)
+
+// AllocatedAuto is recorded as the allocator of rooms given by
+// AllocateRooms, rooms assigned by hand record the admin.
+const AllocatedAuto = "auto"
+
+var (
+	ErrHostelNotFound     = errors.New("hostel not found")
+	ErrRoomNotFound       = errors.New("room not found")
+	ErrRoomFull           = errors.New("room is full")
+	ErrGenderMismatch     = errors.New("hostel does not accommodate the holder's gender")
+	ErrNoAccommodation    = errors.New("ticket does not include accommodation")
+	ErrAllocationNotFound = errors.New("room allocation not found")
+	ErrAllocationState    = errors.New("room allocation is not in the right state")
+)
+
+// Tickets that include a night's stay, either from the tier or bought as
+// an add-on.
+const accommodationTicketsWhere = `
+	pt.status = 'active' AND (pt.isAccommodation = TRUE OR EXISTS(
+		SELECT 1 FROM entitlements e WHERE e.ticket_title = pt.ticket_title AND e.kind = 'night'
+	))
+`
+
+// Allocations of active tickets whose holder has not checked out take up a
+// bed.
+const occupiedBedsQuery = `
+	SELECT COUNT(*) FROM room_allocations a
+	JOIN purchased_tickets pt ON pt.id = a.ticket_id AND pt.status = 'active'
+	WHERE a.room_id = r.id AND a.status != 'checked_out'
+`
+
+// genderFits reports whether a hostel can house someone of the gender.
+func genderFits(hostelGender, gender string) bool {
+	return hostelGender == GenderAny || hostelGender == gender
+}
+
+// ValidGender reports whether a user can give the gender.
+func ValidGender(gender string) bool {
+	return gender == GenderMale || gender == GenderFemale || gender == GenderOther
+}
+
+// SetUserGender records the user's gender, which rooms are allocated by.
+func SetUserGender(ctx context.Context, userID int, gender string) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	if _, err := db.ExecContext(ctx, `UPDATE users SET gender = ? WHERE id = ?`, gender, userID); err != nil {
+		return fmt.Errorf("failed to update gender: %w", err)
+	}
+	return nil
+}
+
+// CreateHostel adds a hostel for the given gender, or GenderAny.
+func CreateHostel(ctx context.Context, name, gender string) (*model.Hostel, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	result, err := db.ExecContext(ctx, `INSERT INTO hostels (name, gender) VALUES (?, ?)`, name, gender)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert hostel: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve last insert ID: %w", err)
+	}
+	return &model.Hostel{ID: int(id), Name: name, Gender: gender, Rooms: []model.Room{}}, nil
+}
+
+// SetRooms adds rooms to a hostel, updating the capacity of rooms that
+// already exist. Capacity cannot be lowered below the beds taken.
+func SetRooms(ctx context.Context, hostelID int, rooms []model.Room) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM hostels WHERE id = ?)`, hostelID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check hostel: %w", err)
+	}
+	if !exists {
+		return ErrHostelNotFound
+	}
+
+	for _, room := range rooms {
+		var occupied int
+		err := tx.QueryRowContext(ctx, `SELECT COALESCE((SELECT (`+occupiedBedsQuery+`) FROM rooms r WHERE r.hostel_id = ? AND r.number = ?), 0)`, hostelID, room.Number).Scan(&occupied)
+		if err != nil {
+			return fmt.Errorf("failed to check room %s: %w", room.Number, err)
+		}
+		if room.Capacity < occupied {
+			return fmt.Errorf("%w: room %s has %d occupants", ErrRoomFull, room.Number, occupied)
+		}
+
+		query := `
+		INSERT INTO rooms (hostel_id, number, capacity) VALUES (?, ?, ?)
+		ON CONFLICT (hostel_id, number) DO UPDATE SET capacity = excluded.capacity
+		`
+		if _, err := tx.ExecContext(ctx, query, hostelID, room.Number, room.Capacity); err != nil {
+			return fmt.Errorf("failed to save room %s: %w", room.Number, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetHostels lists every hostel with its rooms and how many beds are taken.
+func GetHostels(ctx context.Context) ([]model.Hostel, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT id, name, gender FROM hostels ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query hostels: %w", err)
+	}
+	hostels := []model.Hostel{}
+	index := make(map[int]int)
+	for rows.Next() {
+		h := model.Hostel{Rooms: []model.Room{}}
+		if err := rows.Scan(&h.ID, &h.Name, &h.Gender); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan hostel: %w", err)
+		}
+		index[h.ID] = len(hostels)
+		hostels = append(hostels, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.QueryContext(ctx, `SELECT r.id, r.hostel_id, r.number, r.capacity, (`+occupiedBedsQuery+`) FROM rooms r ORDER BY r.hostel_id, r.id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rooms: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r model.Room
+		if err := rows.Scan(&r.ID, &r.HostelID, &r.Number, &r.Capacity, &r.Occupied); err != nil {
+			return nil, fmt.Errorf("failed to scan room: %w", err)
+		}
+		h := &hostels[index[r.HostelID]]
+		h.Rooms = append(h.Rooms, r)
+		h.Capacity += r.Capacity
+		h.Occupied += r.Occupied
+	}
+	return hostels, rows.Err()
+}
+
+const allocationColumns = `
+	a.ticket_id, u.name, u.email, u.gender, h.id, h.name, r.id, r.number, a.status, a.allocated_by, a.allocated_at, a.checked_in_at, a.checked_out_at
+	FROM room_allocations a
+	JOIN rooms r ON r.id = a.room_id
+	JOIN hostels h ON h.id = r.hostel_id
+	JOIN purchased_tickets pt ON pt.id = a.ticket_id AND pt.status = 'active'
+	JOIN users u ON u.id = pt.user_id
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAllocation(row rowScanner) (*model.RoomAllocation, error) {
+	var a model.RoomAllocation
+	err := row.Scan(&a.TicketID, &a.Name, &a.Email, &a.Gender, &a.HostelID, &a.Hostel, &a.RoomID, &a.Room, &a.Status, &a.AllocatedBy, &a.AllocatedAt, &a.CheckedInAt, &a.CheckedOutAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAllocationNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch room allocation: %w", err)
+	}
+	return &a, nil
+}
+
+// GetAllocations lists room allocations, of one hostel when hostelID is
+// not zero.
+func GetAllocations(ctx context.Context, hostelID int) ([]model.RoomAllocation, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT `+allocationColumns+` WHERE ? = 0 OR h.id = ? ORDER BY h.id, r.id, a.ticket_id`, hostelID, hostelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query room allocations: %w", err)
+	}
+	defer rows.Close()
+
+	allocations := []model.RoomAllocation{}
+	for rows.Next() {
+		a, err := scanAllocation(rows)
+		if err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, *a)
+	}
+	return allocations, rows.Err()
+}
+
+// GetTicketAllocation returns the room allocated to a ticket.
+func GetTicketAllocation(ctx context.Context, ticketID int) (*model.RoomAllocation, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+	return scanAllocation(db.QueryRowContext(ctx, `SELECT `+allocationColumns+` WHERE a.ticket_id = ?`, ticketID))
+}
+
+type freeRoom struct {
+	id     int
+	gender string
+	free   int
+}
+
+// AllocateRooms gives a bed to every accommodation holder who has none,
+// filling rooms in order so they are shared rather than spread out. Holders
+// that could not be placed are returned with the reason.
+func AllocateRooms(ctx context.Context) (int, []model.UnallocatedTicket, error) {
+	if db == nil {
+		return 0, nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Gender specific hostels are filled before shared ones
+	rows, err := tx.QueryContext(ctx, `
+		SELECT r.id, h.gender, r.capacity - (`+occupiedBedsQuery+`)
+		FROM rooms r JOIN hostels h ON h.id = r.hostel_id
+		ORDER BY h.gender = 'any', h.id, r.id
+	`)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to query rooms: %w", err)
+	}
+	var rooms []freeRoom
+	for rows.Next() {
+		var r freeRoom
+		if err := rows.Scan(&r.id, &r.gender, &r.free); err != nil {
+			rows.Close()
+			return 0, nil, fmt.Errorf("failed to scan room: %w", err)
+		}
+		rooms = append(rooms, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx, `
+		SELECT pt.id, u.email, u.gender
+		FROM purchased_tickets pt JOIN users u ON u.id = pt.user_id
+		LEFT JOIN room_allocations a ON a.ticket_id = pt.id
+		WHERE a.ticket_id IS NULL AND `+accommodationTicketsWhere+`
+		ORDER BY pt.id
+	`)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to query accommodation holders: %w", err)
+	}
+	var holders []model.UnallocatedTicket
+	for rows.Next() {
+		var h model.UnallocatedTicket
+		if err := rows.Scan(&h.TicketID, &h.Email, &h.Gender); err != nil {
+			rows.Close()
+			return 0, nil, fmt.Errorf("failed to scan accommodation holder: %w", err)
+		}
+		holders = append(holders, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	allocated := 0
+	unallocated := []model.UnallocatedTicket{}
+	for _, holder := range holders {
+		if holder.Gender == "" {
+			holder.Reason = "gender not set"
+			unallocated = append(unallocated, holder)
+			continue
+		}
+
+		room := -1
+		for i := range rooms {
+			if rooms[i].free > 0 && genderFits(rooms[i].gender, holder.Gender) {
+				room = i
+				break
+			}
+		}
+		if room < 0 {
+			holder.Reason = "no room available"
+			unallocated = append(unallocated, holder)
+			continue
+		}
+
+		if err := allocate(ctx, tx, holder.TicketID, rooms[room].id, AllocatedAuto); err != nil {
+			return 0, nil, err
+		}
+		rooms[room].free--
+		allocated++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Allocated rooms to %d accommodation holders, %d left without one", allocated, len(unallocated))
+	return allocated, unallocated, nil
+}
+
+// allocate puts a ticket in a room, replacing the allocation it had.
+func allocate(ctx context.Context, ex execer, ticketID, roomID int, allocatedBy string) error {
+	query := `
+	INSERT INTO room_allocations (ticket_id, room_id, allocated_by) VALUES (?, ?, ?)
+	ON CONFLICT (ticket_id) DO UPDATE SET
+		room_id = excluded.room_id, status = 'allocated', allocated_by = excluded.allocated_by,
+		allocated_at = CURRENT_TIMESTAMP, checked_in_at = NULL, checked_out_at = NULL
+	`
+	if _, err := ex.ExecContext(ctx, query, ticketID, roomID, allocatedBy); err != nil {
+		return fmt.Errorf("failed to allocate room: %w", err)
+	}
+	return nil
+}
+
+// AssignRoom puts an accommodation holder in a specific room, moving them
+// if they already had one. Holders who have not given their gender can be
+// placed in any hostel.
+func AssignRoom(ctx context.Context, ticketID, roomID int, allocatedBy string) (*model.RoomAllocation, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var gender string
+	err = tx.QueryRowContext(ctx, `SELECT u.gender FROM purchased_tickets pt JOIN users u ON u.id = pt.user_id WHERE pt.id = ? AND `+accommodationTicketsWhere, ticketID).Scan(&gender)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoAccommodation
+		}
+		return nil, fmt.Errorf("failed to fetch ticket: %w", err)
+	}
+
+	// The holder's own bed does not count when they stay in the same room
+	var hostelGender string
+	var free int
+	err = tx.QueryRowContext(ctx, `
+		SELECT h.gender, r.capacity - (`+occupiedBedsQuery+` AND a.ticket_id != ?)
+		FROM rooms r JOIN hostels h ON h.id = r.hostel_id WHERE r.id = ?
+	`, ticketID, roomID).Scan(&hostelGender, &free)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRoomNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch room: %w", err)
+	}
+	if gender != "" && !genderFits(hostelGender, gender) {
+		return nil, ErrGenderMismatch
+	}
+	if free <= 0 {
+		return nil, ErrRoomFull
+	}
+
+	if err := allocate(ctx, tx, ticketID, roomID, allocatedBy); err != nil {
+		return nil, err
+	}
+	allocation, err := scanAllocation(tx.QueryRowContext(ctx, `SELECT `+allocationColumns+` WHERE a.ticket_id = ?`, ticketID))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return allocation, nil
+}
+
+// ReleaseRoom frees the bed allocated to a ticket.
+func ReleaseRoom(ctx context.Context, ticketID int) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	result, err := db.ExecContext(ctx, `DELETE FROM room_allocations WHERE ticket_id = ?`, ticketID)
+	if err != nil {
+		return fmt.Errorf("failed to release room: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrAllocationNotFound
+	}
+	return nil
+}
+
+// HostelCheckIn records the holder of a ticket arriving at their room.
+func HostelCheckIn(ctx context.Context, ticketID int) (*model.RoomAllocation, error) {
+	return moveAllocation(ctx, ticketID, AllocationAllocated, AllocationCheckedIn, "checked_in_at")
+}
+
+// HostelCheckOut records the holder of a ticket leaving, which frees their
+// bed.
+func HostelCheckOut(ctx context.Context, ticketID int) (*model.RoomAllocation, error) {
+	return moveAllocation(ctx, ticketID, AllocationCheckedIn, AllocationCheckedOut, "checked_out_at")
+}
+
+func moveAllocation(ctx context.Context, ticketID int, from, to, column string) (*model.RoomAllocation, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	allocation, err := GetTicketAllocation(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if allocation.Status != from {
+		return allocation, ErrAllocationState
+	}
+
+	now := time.Now().UTC()
+	result, err := db.ExecContext(ctx, `UPDATE room_allocations SET status = ?, `+column+` = ? WHERE ticket_id = ? AND status = ?`, to, now.Format(sqliteTime), ticketID, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update room allocation: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return allocation, ErrAllocationState
+	}
+
+	at := now.Format(time.RFC3339)
+	allocation.Status = to
+	if to == AllocationCheckedIn {
+		allocation.CheckedInAt = &at
+	} else {
+		allocation.CheckedOutAt = &at
+	}
+	return allocation, nil
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"reg/internal/model"
+)
+
+// testHolder adds a user of the gender with a pass of the tier and returns
+// its ticket id.
+func testHolder(t *testing.T, email, gender, tier string) int {
+	t.Helper()
+	ticket := createTestTicket(t, email, tier)
+	if err := SetUserGender(context.Background(), ticket.ID, gender); err != nil {
+		t.Fatal(err)
+	}
+	return ticket.TicketID
+}
+
+// testRoom adds a hostel with a single room of the capacity and returns the
+// room id.
+func testRoom(t *testing.T, name, gender string, capacity int) int {
+	t.Helper()
+	ctx := context.Background()
+	hostel, err := CreateHostel(ctx, name, gender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetRooms(ctx, hostel.ID, []model.Room{{Number: "101", Capacity: capacity}}); err != nil {
+		t.Fatal(err)
+	}
+	var roomID int
+	if err := db.QueryRow(`SELECT id FROM rooms WHERE hostel_id = ?`, hostel.ID).Scan(&roomID); err != nil {
+		t.Fatal(err)
+	}
+	return roomID
+}
+
+func TestGenderFits(t *testing.T) {
+	tests := []struct {
+		hostel, gender string
+		want           bool
+	}{
+		{GenderMale, GenderMale, true},
+		{GenderMale, GenderFemale, false},
+		{GenderFemale, GenderFemale, true},
+		{GenderFemale, GenderOther, false},
+		{GenderAny, GenderMale, true},
+		{GenderAny, GenderOther, true},
+	}
+	for _, tt := range tests {
+		if got := genderFits(tt.hostel, tt.gender); got != tt.want {
+			t.Errorf("genderFits(%q, %q) = %v, want %v", tt.hostel, tt.gender, got, tt.want)
+		}
+	}
+}
+
+// allocations runs AllocateRooms and maps the holders of tickets to their
+// hostel, or to the reason they were left without a room.
+func allocations(t *testing.T, tickets map[string]int) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+	allocated, unallocated, err := AllocateRooms(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]string)
+	for _, u := range unallocated {
+		got[u.Email] = u.Reason
+	}
+	rooms := 0
+	for email, ticketID := range tickets {
+		allocation, err := GetTicketAllocation(ctx, ticketID)
+		if errors.Is(err, ErrAllocationNotFound) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[email] = allocation.Hostel
+		rooms++
+	}
+	if allocated != rooms {
+		t.Errorf("AllocateRooms reported %d allocated, found %d", allocated, rooms)
+	}
+	return got
+}
+
+func TestAllocateRoomsFillsGenderHostelsFirst(t *testing.T) {
+	openTestDB(t)
+	testRoom(t, "Shared", GenderAny, 2)
+	testRoom(t, "Boys", GenderMale, 1)
+	tickets := map[string]int{
+		"m1": testHolder(t, "m1", GenderMale, "PREMIUM"),
+		"m2": testHolder(t, "m2", GenderMale, "PREMIUM"),
+		"f1": testHolder(t, "f1", GenderFemale, "PREMIUM"),
+	}
+
+	want := map[string]string{"m1": "Boys", "m2": "Shared", "f1": "Shared"}
+	if got := allocations(t, tickets); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestAllocateRoomsWithoutFittingRoom(t *testing.T) {
+	openTestDB(t)
+	testRoom(t, "Boys", GenderMale, 2)
+	testRoom(t, "Girls", GenderFemale, 1)
+	tickets := map[string]int{
+		"f1": testHolder(t, "f1", GenderFemale, "PREMIUM"),
+		"f2": testHolder(t, "f2", GenderFemale, "PREMIUM"),
+		"x1": testHolder(t, "x1", GenderOther, "PREMIUM"),
+	}
+
+	want := map[string]string{"f1": "Girls", "f2": "no room available", "x1": "no room available"}
+	if got := allocations(t, tickets); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestAllocateRoomsSkipsHolders(t *testing.T) {
+	openTestDB(t)
+	testRoom(t, "Shared", GenderAny, 3)
+	tickets := map[string]int{
+		"x1": testHolder(t, "x1", "", "PREMIUM"),
+		"s1": testHolder(t, "s1", GenderMale, "STANDARD"),
+		"p1": testHolder(t, "p1", GenderMale, "PREMIUM"),
+	}
+
+	// Tiers without accommodation are not even listed
+	want := map[string]string{"x1": "gender not set", "p1": "Shared"}
+	if got := allocations(t, tickets); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestAssignRoom(t *testing.T) {
+	ctx := context.Background()
+	openTestDB(t)
+	girls := testRoom(t, "Girls", GenderFemale, 1)
+	shared := testRoom(t, "Shared", GenderAny, 2)
+
+	ticketID := testHolder(t, "f1", GenderFemale, "PREMIUM")
+	allocation, err := AssignRoom(ctx, ticketID, girls, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allocation.RoomID != girls || allocation.Status != AllocationAllocated {
+		t.Errorf("got allocation %+v", allocation)
+	}
+	// Their own bed does not fill the room
+	if _, err := AssignRoom(ctx, ticketID, girls, "admin"); err != nil {
+		t.Errorf("assigning again: %v", err)
+	}
+
+	if _, err := AssignRoom(ctx, testHolder(t, "x1", GenderOther, "PREMIUM"), shared, "admin"); err != nil {
+		t.Errorf("shared hostel: %v", err)
+	}
+	// Admins can place holders who have not set a gender anywhere
+	if _, err := AssignRoom(ctx, testHolder(t, "u1", "", "PREMIUM"), shared, "admin"); err != nil {
+		t.Errorf("gender not set: %v", err)
+	}
+}
+
+func TestAssignRoomRefusals(t *testing.T) {
+	ctx := context.Background()
+	openTestDB(t)
+	boys := testRoom(t, "Boys", GenderMale, 1)
+	girls := testRoom(t, "Girls", GenderFemale, 1)
+
+	if _, err := AssignRoom(ctx, testHolder(t, "m1", GenderMale, "PREMIUM"), girls, "admin"); !errors.Is(err, ErrGenderMismatch) {
+		t.Errorf("gender mismatch: got error %v", err)
+	}
+	if _, err := AssignRoom(ctx, testHolder(t, "s1", GenderMale, "STANDARD"), boys, "admin"); !errors.Is(err, ErrNoAccommodation) {
+		t.Errorf("no accommodation: got error %v", err)
+	}
+
+	if _, err := AssignRoom(ctx, testHolder(t, "m2", GenderMale, "PREMIUM"), boys, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AssignRoom(ctx, testHolder(t, "m3", GenderMale, "PREMIUM"), boys, "admin"); !errors.Is(err, ErrRoomFull) {
+		t.Errorf("room full: got error %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reg/internal/model"
+)
+
+// RecordBounce stores a failed recipient of a delivery status notification
+// and marks the user with that address as undeliverable. messageKey
+// identifies the notification, it returns false when this recipient of it
+// was already recorded.
+func RecordBounce(ctx context.Context, messageKey string, b model.Bounce) (bool, error) {
+	if db == nil {
+		return false, fmt.Errorf("database connection is not initialized")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO email_bounces (message_key, email, user_id, status, diagnostic)
+	VALUES (?, ?, (SELECT id FROM users WHERE LOWER(email) = LOWER(?)), ?, ?)
+	ON CONFLICT (message_key, email) DO NOTHING`
+	result, err := tx.ExecContext(ctx, query, messageKey, b.Email, b.Email, b.Status, b.Diagnostic)
+	if err != nil {
+		return false, fmt.Errorf("failed to record bounce: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	reason := b.Status
+	if b.Diagnostic != "" {
+		reason = b.Diagnostic
+	}
+	query = `
+	UPDATE users
+	SET email_undeliverable = TRUE, email_bounced_at = CURRENT_TIMESTAMP, email_bounce_reason = ?
+	WHERE LOWER(email) = LOWER(?)`
+	if _, err := tx.ExecContext(ctx, query, reason, b.Email); err != nil {
+		return false, fmt.Errorf("failed to mark user undeliverable: %w", err)
+	}
+	return true, tx.Commit()
+}
+
+// GetUndeliverableUsers lists the users whose email address bounced, most
+// recent first.
+func GetUndeliverableUsers(ctx context.Context) ([]model.UndeliverableUser, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	SELECT u.id, u.name, u.email, u.contact_number, u.email_bounced_at, u.email_bounce_reason,
+		(SELECT COUNT(*) FROM email_bounces b WHERE b.user_id = u.id)
+	FROM users u
+	WHERE u.email_undeliverable = TRUE
+	ORDER BY u.email_bounced_at DESC, u.id`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query undeliverable users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []model.UndeliverableUser{}
+	for rows.Next() {
+		var (
+			u         model.UndeliverableUser
+			bouncedAt sql.NullString
+		)
+		if err := rows.Scan(&u.UserID, &u.Name, &u.Email, &u.ContactNumber, &bouncedAt, &u.Reason, &u.Bounces); err != nil {
+			return nil, fmt.Errorf("failed to scan undeliverable user: %w", err)
+		}
+		u.BouncedAt = bouncedAt.String
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// ClearUndeliverable marks a user's address as deliverable again, once the
+// help desk has confirmed or corrected it.
+func ClearUndeliverable(ctx context.Context, userID int) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	UPDATE users SET email_undeliverable = FALSE, email_bounce_reason = ''
+	WHERE id = ? AND email_undeliverable = TRUE`
+	result, err := db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("failed to clear undeliverable user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
@@ -0,0 +1,459 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reg/internal/model"
+	"strings"
+)
+
+// Campaign statuses
+const (
+	CampaignDraft     = "draft"
+	CampaignSending   = "sending"
+	CampaignCompleted = "completed"
+	CampaignCancelled = "cancelled"
+)
+
+// Campaign recipient statuses. A queued recipient is reported as sent or
+// failed once the outbox has delivered or given up on their email.
+const (
+	RecipientPending   = "pending"
+	RecipientQueued    = "queued"
+	RecipientSent      = "sent"
+	RecipientFailed    = "failed"
+	RecipientCancelled = "cancelled"
+)
+
+var (
+	ErrCampaignNotFound = errors.New("campaign not found")
+	ErrCampaignState    = errors.New("campaign cannot be changed in its current state")
+)
+
+// segmentWhere is the condition on users u matching a segment. Users whose
+// address bounced are never part of one.
+func segmentWhere(segment model.CampaignSegment) (string, []any) {
+	conds := []string{"u.email_undeliverable = FALSE"}
+	var args []any
+
+	if segment.Purchased != nil {
+		exists := `EXISTS (SELECT 1 FROM purchased_tickets pt WHERE pt.user_id = u.id AND pt.status = 'active')`
+		if !*segment.Purchased {
+			exists = "NOT " + exists
+		}
+		conds = append(conds, exists)
+	}
+	if len(segment.Tiers) > 0 {
+		conds = append(conds, `EXISTS (SELECT 1 FROM purchased_tickets pt WHERE pt.user_id = u.id AND pt.status = 'active' AND pt.ticket_title IN (?`+strings.Repeat(", ?", len(segment.Tiers)-1)+`))`)
+		for _, tier := range segment.Tiers {
+			args = append(args, tier)
+		}
+	}
+	if segment.CheckedIn != nil {
+		exists := `EXISTS (
+			SELECT 1 FROM checkins c
+			JOIN purchased_tickets pt ON pt.id = c.ticket_id
+			WHERE pt.user_id = u.id AND c.result = 'admitted' AND c.zone = ''
+		)`
+		if !*segment.CheckedIn {
+			exists = "NOT " + exists
+		}
+		conds = append(conds, exists)
+	}
+	if len(segment.Colleges) > 0 {
+		// data is whatever JSON the signup form sent, it may not be valid
+		conds = append(conds, `LOWER(TRIM(CASE WHEN json_valid(u.data) THEN json_extract(u.data, '$.college') END)) IN (?`+strings.Repeat(", ?", len(segment.Colleges)-1)+`)`)
+		for _, college := range segment.Colleges {
+			args = append(args, strings.ToLower(strings.TrimSpace(college)))
+		}
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// PreviewSegment counts the users of a segment who receive emails of
+// category and those who opted out of them, and returns a few of the first.
+func PreviewSegment(ctx context.Context, segment model.CampaignSegment, category string, sample int) (int, int, []model.CampaignRecipient, error) {
+	if db == nil {
+		return 0, 0, nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	where, args := segmentWhere(segment)
+	var count, optedOut int
+	query := `SELECT COUNT(*) FILTER (WHERE ` + optedIn(category) + `), COUNT(*) FILTER (WHERE NOT ` + optedIn(category) + `) FROM users u WHERE ` + where
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&count, &optedOut); err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to count segment: %w", err)
+	}
+	where += " AND " + optedIn(category)
+
+	rows, err := db.QueryContext(ctx, `SELECT u.id, u.name, u.email FROM users u WHERE `+where+` ORDER BY u.id LIMIT ?`, append(args, sample)...)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to query segment: %w", err)
+	}
+	defer rows.Close()
+
+	users := []model.CampaignRecipient{}
+	for rows.Next() {
+		var r model.CampaignRecipient
+		if err := rows.Scan(&r.UserID, &r.Name, &r.Email); err != nil {
+			return 0, 0, nil, fmt.Errorf("failed to scan segment: %w", err)
+		}
+		users = append(users, r)
+	}
+	return count, optedOut, users, rows.Err()
+}
+
+const campaignColumns = `id, name, template, subject, heading, message, action_text, action_url, segment, category, rate_per_minute, status, created_by, created_at, started_at, finished_at`
+
+func scanCampaign(row rowScanner) (*model.Campaign, error) {
+	var (
+		c                 model.Campaign
+		segment           string
+		started, finished sql.NullString
+	)
+	if err := row.Scan(&c.ID, &c.Name, &c.Template, &c.Subject, &c.Heading, &c.Message, &c.ActionText, &c.ActionURL, &segment, &c.Category, &c.RatePerMinute, &c.Status, &c.CreatedBy, &c.CreatedAt, &started, &finished); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(segment), &c.Segment); err != nil {
+		return nil, fmt.Errorf("failed to decode segment of campaign %d: %w", c.ID, err)
+	}
+	if started.Valid {
+		c.StartedAt = &started.String
+	}
+	if finished.Valid {
+		c.FinishedAt = &finished.String
+	}
+	return &c, nil
+}
+
+// CreateCampaign saves a campaign as a draft.
+func CreateCampaign(ctx context.Context, c model.Campaign) (*model.Campaign, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	segment, err := json.Marshal(c.Segment)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+	INSERT INTO campaigns (name, template, subject, heading, message, action_text, action_url, segment, category, rate_per_minute, created_by)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	RETURNING ` + campaignColumns
+	created, err := scanCampaign(db.QueryRowContext(ctx, query, c.Name, c.Template, c.Subject, c.Heading, c.Message, c.ActionText, c.ActionURL, string(segment), c.Category, c.RatePerMinute, c.CreatedBy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create campaign: %w", err)
+	}
+	return created, nil
+}
+
+// GetCampaign returns a campaign.
+func GetCampaign(ctx context.Context, id int) (*model.Campaign, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	c, err := scanCampaign(db.QueryRowContext(ctx, `SELECT `+campaignColumns+` FROM campaigns WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch campaign: %w", err)
+	}
+	return c, nil
+}
+
+// GetCampaigns lists campaigns, newest first.
+func GetCampaigns(ctx context.Context) ([]model.Campaign, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT `+campaignColumns+` FROM campaigns ORDER BY id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	campaigns := []model.Campaign{}
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan campaign: %w", err)
+		}
+		campaigns = append(campaigns, *c)
+	}
+	return campaigns, rows.Err()
+}
+
+// GetSendingCampaigns returns the ids of campaigns that are being sent.
+func GetSendingCampaigns(ctx context.Context) ([]int, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT id FROM campaigns WHERE status = 'sending' ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sending campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// StartCampaign fixes the recipients of a draft campaign from the users of
+// its segment who receive its category of email, and marks it as sending. It returns the number of recipients.
+func StartCampaign(ctx context.Context, id int) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is not initialized")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	c, err := scanCampaign(tx.QueryRowContext(ctx, `SELECT `+campaignColumns+` FROM campaigns WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrCampaignNotFound
+		}
+		return 0, fmt.Errorf("failed to fetch campaign: %w", err)
+	}
+	if c.Status != CampaignDraft {
+		return 0, ErrCampaignState
+	}
+
+	where, args := segmentWhere(c.Segment)
+	query := `
+	INSERT INTO campaign_recipients (campaign_id, user_id, email, name)
+	SELECT ?, u.id, u.email, u.name FROM users u WHERE ` + where + ` AND ` + optedIn(c.Category)
+	result, err := tx.ExecContext(ctx, query, append([]any{id}, args...)...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add campaign recipients: %w", err)
+	}
+	n, _ := result.RowsAffected()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE campaigns SET status = 'sending', started_at = CURRENT_TIMESTAMP WHERE id = ?`, id); err != nil {
+		return 0, fmt.Errorf("failed to start campaign: %w", err)
+	}
+	return int(n), tx.Commit()
+}
+
+// NextCampaignRecipient returns the next recipient a campaign has not
+// mailed yet, nil when there is none or the campaign is no longer sending.
+func NextCampaignRecipient(ctx context.Context, id int) (*model.CampaignRecipient, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	SELECT r.user_id, r.name, r.email
+	FROM campaign_recipients r
+	JOIN campaigns c ON c.id = r.campaign_id
+	WHERE r.campaign_id = ? AND r.status = 'pending' AND c.status = 'sending'
+	ORDER BY r.user_id
+	LIMIT 1
+	`
+	var r model.CampaignRecipient
+	if err := db.QueryRowContext(ctx, query, id).Scan(&r.UserID, &r.Name, &r.Email); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch campaign recipient: %w", err)
+	}
+	r.Status = RecipientPending
+	return &r, nil
+}
+
+// QueueCampaignEmail stores the campaign email of a recipient in the outbox
+// and marks them as queued in one transaction, so a campaign resumed after
+// a crash never mails anyone twice. It returns the outbox id of the email,
+// or ErrCampaignState when the recipient is no longer pending.
+func QueueCampaignEmail(ctx context.Context, id, userID int, e model.OutboxEmail) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is not initialized")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	outboxID, err := enqueueEmail(ctx, tx, e)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE campaign_recipients SET status = 'queued', outbox_id = ?, queued_at = CURRENT_TIMESTAMP WHERE campaign_id = ? AND user_id = ? AND status = 'pending'`, outboxID, id, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record campaign recipient: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return 0, ErrCampaignState
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return outboxID, nil
+}
+
+// FailCampaignRecipient records the error that kept a recipient's email
+// from being queued.
+func FailCampaignRecipient(ctx context.Context, id, userID int, queueErr error) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `UPDATE campaign_recipients SET status = 'failed', error = ? WHERE campaign_id = ? AND user_id = ? AND status = 'pending'`
+	if _, err := db.ExecContext(ctx, query, queueErr.Error(), id, userID); err != nil {
+		return fmt.Errorf("failed to record campaign recipient: %w", err)
+	}
+	return nil
+}
+
+// FinishCampaign marks a sending campaign as completed.
+func FinishCampaign(ctx context.Context, id int) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	if _, err := db.ExecContext(ctx, `UPDATE campaigns SET status = 'completed', finished_at = CURRENT_TIMESTAMP WHERE id = ? AND status = 'sending'`, id); err != nil {
+		return fmt.Errorf("failed to finish campaign: %w", err)
+	}
+	return nil
+}
+
+// CancelCampaign stops a draft or sending campaign. Emails already queued
+// are still delivered.
+func CancelCampaign(ctx context.Context, id int) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE campaigns SET status = 'cancelled', finished_at = CURRENT_TIMESTAMP WHERE id = ? AND status IN ('draft', 'sending')`, id)
+	if err != nil {
+		return fmt.Errorf("failed to cancel campaign: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM campaigns WHERE id = ?)`, id).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to fetch campaign: %w", err)
+		}
+		if !exists {
+			return ErrCampaignNotFound
+		}
+		return ErrCampaignState
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE campaign_recipients SET status = 'cancelled' WHERE campaign_id = ? AND status = 'pending'`, id); err != nil {
+		return fmt.Errorf("failed to cancel campaign recipients: %w", err)
+	}
+	return tx.Commit()
+}
+
+// recipientStatus reports a queued recipient by the state of their email in
+// the outbox.
+const recipientStatus = `
+	CASE
+		WHEN r.status != 'queued' THEN r.status
+		WHEN o.status = 'sent' THEN 'sent'
+		WHEN o.status = 'dead' THEN 'failed'
+		ELSE 'queued'
+	END`
+
+// GetCampaignSummary counts the recipients of a campaign by status.
+func GetCampaignSummary(ctx context.Context, id int) (map[string]int, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	SELECT ` + recipientStatus + `, COUNT(*)
+	FROM campaign_recipients r
+	LEFT JOIN email_outbox o ON o.id = r.outbox_id
+	WHERE r.campaign_id = ?
+	GROUP BY 1
+	`
+	rows, err := db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query campaign summary: %w", err)
+	}
+	defer rows.Close()
+
+	summary := map[string]int{RecipientPending: 0, RecipientQueued: 0, RecipientSent: 0, RecipientFailed: 0, RecipientCancelled: 0}
+	for rows.Next() {
+		var (
+			status string
+			n      int
+		)
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan campaign summary: %w", err)
+		}
+		summary[status] = n
+	}
+	return summary, rows.Err()
+}
+
+// GetCampaignRecipients lists the recipients of a campaign, of one status
+// when status is not empty.
+func GetCampaignRecipients(ctx context.Context, id int, status string, limit, offset int) ([]model.CampaignRecipient, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	SELECT r.user_id, r.name, r.email, ` + recipientStatus + ` AS status,
+		CASE WHEN o.status = 'dead' THEN COALESCE(o.last_error, '') ELSE COALESCE(r.error, '') END,
+		r.outbox_id, r.queued_at
+	FROM campaign_recipients r
+	LEFT JOIN email_outbox o ON o.id = r.outbox_id
+	WHERE r.campaign_id = ? AND (? = '' OR ` + recipientStatus + ` = ?)
+	ORDER BY r.user_id
+	LIMIT ? OFFSET ?
+	`
+	rows, err := db.QueryContext(ctx, query, id, status, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query campaign recipients: %w", err)
+	}
+	defer rows.Close()
+
+	recipients := []model.CampaignRecipient{}
+	for rows.Next() {
+		var (
+			r        model.CampaignRecipient
+			outboxID sql.NullInt64
+			queuedAt sql.NullString
+		)
+		if err := rows.Scan(&r.UserID, &r.Name, &r.Email, &r.Status, &r.Error, &outboxID, &queuedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan campaign recipient: %w", err)
+		}
+		if outboxID.Valid {
+			id := int(outboxID.Int64)
+			r.OutboxID = &id
+		}
+		if queuedAt.Valid {
+			r.QueuedAt = &queuedAt.String
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, rows.Err()
+}
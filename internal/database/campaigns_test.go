@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"reg/internal/model"
+)
+
+// startTestCampaign starts an announcement to a single reader and returns
+// the campaign, the reader and the email to queue for them.
+func startTestCampaign(t *testing.T) (int, int, model.OutboxEmail) {
+	t.Helper()
+	ctx := context.Background()
+	userID := createTestUser(t, "reader@example.com")
+	c, err := CreateCampaign(ctx, model.Campaign{Name: "News", Template: "announcement", Subject: "News", Category: EmailAnnouncements, RatePerMinute: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StartCampaign(ctx, c.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	email := model.OutboxEmail{From: "events@example.com", Recipients: []string{"reader@example.com"}, To: "reader@example.com", Subject: "News", Message: []byte("hi")}
+	return c.ID, userID, email
+}
+
+func TestQueueCampaignEmail(t *testing.T) {
+	ctx := context.Background()
+	openTestDB(t)
+	campaignID, userID, email := startTestCampaign(t)
+
+	outboxID, err := QueueCampaignEmail(ctx, campaignID, userID, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := NextCampaignRecipient(ctx, campaignID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != nil {
+		t.Errorf("got next recipient %+v, want none", next)
+	}
+	recipients, err := GetCampaignRecipients(ctx, campaignID, "", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipients) != 1 || recipients[0].OutboxID == nil || int64(*recipients[0].OutboxID) != outboxID {
+		t.Errorf("got recipients %+v, want one linked to outbox email %d", recipients, outboxID)
+	}
+}
+
+func TestQueueCampaignEmailOnce(t *testing.T) {
+	ctx := context.Background()
+	openTestDB(t)
+	campaignID, userID, email := startTestCampaign(t)
+
+	if _, err := QueueCampaignEmail(ctx, campaignID, userID, email); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := QueueCampaignEmail(ctx, campaignID, userID, email); !errors.Is(err, ErrCampaignState) {
+		t.Fatalf("queueing again: got error %v, want %v", err, ErrCampaignState)
+	}
+
+	// The refused email is not left in the outbox
+	var emails int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM email_outbox`).Scan(&emails); err != nil {
+		t.Fatal(err)
+	}
+	if emails != 1 {
+		t.Errorf("got %d outbox emails, want 1", emails)
+	}
+}
@@ -0,0 +1,268 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reg/internal/model"
+	"strings"
+	"time"
+)
+
+// Check-in results
+const (
+	CheckInAdmitted    = "admitted"
+	CheckInAlreadyUsed = "already_used"
+	CheckInRevoked     = "revoked"
+	CheckInInvalid     = "invalid"
+	CheckInDenied      = "denied"
+)
+
+// ScannerTokenPrefix marks scanner tokens so they can be told apart from
+// user session tokens without a database lookup.
+const ScannerTokenPrefix = "scn_"
+
+var ErrScannerNotFound = errors.New("scanner not found")
+
+// hashToken is the form scanner and transfer tokens are stored in.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateScanner registers a scanner and returns it with its token. Only a
+// hash of the token is stored, so it cannot be shown again. Scanners with a
+// zone admit against that entitlement, the others check people in at the
+// gate.
+func CreateScanner(ctx context.Context, name, gate, zone, createdBy string) (*model.Scanner, string, error) {
+	if db == nil {
+		return nil, "", fmt.Errorf("database connection is not initialized")
+	}
+
+	if zone != "" {
+		var exists bool
+		if err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM entitlements WHERE code = ?)`, zone).Scan(&exists); err != nil {
+			return nil, "", fmt.Errorf("failed to check zone: %w", err)
+		}
+		if !exists {
+			return nil, "", ErrEntitlementNotFound
+		}
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", fmt.Errorf("failed to generate scanner token: %w", err)
+	}
+	token := ScannerTokenPrefix + hex.EncodeToString(raw)
+
+	result, err := db.ExecContext(ctx, `INSERT INTO scanners (name, gate, zone, token_hash, created_by) VALUES (?, ?, ?, ?, ?)`, name, gate, zone, hashToken(token), createdBy)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to insert scanner: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to retrieve last insert ID: %w", err)
+	}
+
+	scanner, err := getScanner(ctx, `id = ?`, id)
+	if err != nil {
+		return nil, "", err
+	}
+	return scanner, token, nil
+}
+
+// GetScannerByToken returns the active scanner owning a token.
+func GetScannerByToken(ctx context.Context, token string) (*model.Scanner, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+	if !strings.HasPrefix(token, ScannerTokenPrefix) {
+		return nil, ErrScannerNotFound
+	}
+	return getScanner(ctx, `token_hash = ? AND is_active = TRUE`, hashToken(token))
+}
+
+func getScanner(ctx context.Context, where string, args ...any) (*model.Scanner, error) {
+	var s model.Scanner
+	err := db.QueryRowContext(ctx, `SELECT id, name, gate, zone, is_active, created_by, created_at, token_hash FROM scanners WHERE `+where, args...).
+		Scan(&s.ID, &s.Name, &s.Gate, &s.Zone, &s.IsActive, &s.CreatedBy, &s.CreatedAt, &s.TokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrScannerNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch scanner: %w", err)
+	}
+	return &s, nil
+}
+
+// GetScanners lists every registered scanner.
+func GetScanners(ctx context.Context) ([]model.Scanner, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT id, name, gate, zone, is_active, created_by, created_at FROM scanners ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scanners: %w", err)
+	}
+	defer rows.Close()
+
+	scanners := []model.Scanner{}
+	for rows.Next() {
+		var s model.Scanner
+		if err := rows.Scan(&s.ID, &s.Name, &s.Gate, &s.Zone, &s.IsActive, &s.CreatedBy, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan scanner: %w", err)
+		}
+		scanners = append(scanners, s)
+	}
+	return scanners, rows.Err()
+}
+
+// SetScannerActive enables or disables a scanner. Disabled scanners are
+// rejected at authentication.
+func SetScannerActive(ctx context.Context, id int, active bool) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	result, err := db.ExecContext(ctx, `UPDATE scanners SET is_active = ? WHERE id = ?`, active, id)
+	if err != nil {
+		return fmt.Errorf("failed to update scanner: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrScannerNotFound
+	}
+	return nil
+}
+
+// CheckIn validates a scanned pass code at a scanner and records the
+// attempt. Gate scanners admit a pass once. Zone scanners admit it only if
+// the tier includes the zone, as many times as the entitlement allows.
+func CheckIn(ctx context.Context, code string, scanner model.Scanner) (*model.CheckIn, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+	return checkIn(ctx, code, scanner, time.Now().UTC(), "")
+}
+
+// checkIn records a scan made at the given time. clientID is set for scans
+// uploaded by a scanner after being recorded offline.
+func checkIn(ctx context.Context, code string, scanner model.Scanner, checkedInAt time.Time, clientID string) (*model.CheckIn, error) {
+	now := checkedInAt.Format(sqliteTime)
+	checkIn := &model.CheckIn{Gate: scanner.Gate, Zone: scanner.Zone, CheckedInAt: checkedInAt.Format(time.RFC3339)}
+	scan := scanRecord{scanner: scanner, code: code, at: now, clientID: clientID}
+
+	ticket, err := VerifyPassCode(ctx, code)
+	if err != nil {
+		if !errors.Is(err, ErrInvalidPass) {
+			return nil, err
+		}
+
+		// The code of a pass that was given to someone else. The new
+		// holder's details are not shown to whoever is still carrying it.
+		ticketID, transferred, err := transferredTicket(ctx, code)
+		if err != nil {
+			return nil, err
+		}
+		if transferred {
+			checkIn.Result = CheckInRevoked
+			return checkIn, scan.record(ctx, &ticketID, CheckInRevoked)
+		}
+
+		checkIn.Result = CheckInInvalid
+		return checkIn, scan.record(ctx, nil, CheckInInvalid)
+	}
+
+	checkIn.TicketID = ticket.TicketID
+	checkIn.Name = ticket.Name
+	checkIn.Email = ticket.Email
+	checkIn.TicketTitle = ticket.TicketTitle
+
+	if ticket.Status != "active" {
+		checkIn.Result = CheckInRevoked
+		return checkIn, scan.record(ctx, &ticket.TicketID, CheckInRevoked)
+	}
+
+	// People are checked in at the gate once
+	maxUses := 1
+	if scanner.Zone != "" {
+		entitlement, err := getTicketEntitlement(ctx, ticket.TicketID, scanner.Zone)
+		if err != nil {
+			if !errors.Is(err, ErrEntitlementNotFound) {
+				return nil, err
+			}
+			checkIn.Result = CheckInDenied
+			return checkIn, scan.record(ctx, &ticket.TicketID, CheckInDenied)
+		}
+		maxUses = 0
+		if entitlement.MaxUses != nil {
+			maxUses = *entitlement.MaxUses
+		}
+	}
+
+	// Check and insert in one statement so two scanners reading the same
+	// pass at once cannot both admit it
+	query := `
+	INSERT INTO checkins (ticket_id, scanner_id, gate, zone, result, scanned_code, checked_in_at, client_id, synced_at)
+	SELECT ?, ?, ?, ?, 'admitted', ?, ?, ?, ?
+	WHERE ? = 0 OR (SELECT COUNT(*) FROM checkins WHERE ticket_id = ? AND zone = ? AND result = 'admitted') < ?
+	`
+	clientIDArg, syncedAt := scan.syncColumns()
+	result, err := db.ExecContext(ctx, query, ticket.TicketID, scanner.ID, scanner.Gate, scanner.Zone, code, now, clientIDArg, syncedAt, maxUses, ticket.TicketID, scanner.Zone, maxUses)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record check-in: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 1 {
+		checkIn.Result = CheckInAdmitted
+		checkIn.Allowed = true
+		if scanner.Zone == "" {
+			// Tell the volunteer at the gate what the pass includes
+			entitlements, err := GetTicketEntitlements(ctx, ticket.TicketID)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range entitlements {
+				checkIn.Entitlements = append(checkIn.Entitlements, e.Name)
+			}
+		}
+		return checkIn, nil
+	}
+
+	checkIn.Result = CheckInAlreadyUsed
+	err = db.QueryRowContext(ctx, `SELECT gate, checked_in_at FROM checkins WHERE ticket_id = ? AND zone = ? AND result = 'admitted' ORDER BY id LIMIT 1`, ticket.TicketID, scanner.Zone).
+		Scan(&checkIn.FirstGate, &checkIn.FirstCheckIn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch first check-in: %w", err)
+	}
+	return checkIn, scan.record(ctx, &ticket.TicketID, CheckInAlreadyUsed)
+}
+
+type scanRecord struct {
+	scanner  model.Scanner
+	code     string
+	at       string
+	clientID string
+}
+
+// syncColumns returns the client id and sync time of offline scans, both
+// NULL for scans made online.
+func (s scanRecord) syncColumns() (any, any) {
+	if s.clientID == "" {
+		return nil, nil
+	}
+	return s.clientID, time.Now().UTC().Format(sqliteTime)
+}
+
+func (s scanRecord) record(ctx context.Context, ticketID *int, result string) error {
+	clientID, syncedAt := s.syncColumns()
+	_, err := db.ExecContext(ctx, `INSERT INTO checkins (ticket_id, scanner_id, gate, zone, result, scanned_code, checked_in_at, client_id, synced_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		ticketID, s.scanner.ID, s.scanner.Gate, s.scanner.Zone, result, s.code, s.at, clientID, syncedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record check-in: %w", err)
+	}
+	return nil
+}
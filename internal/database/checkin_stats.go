@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reg/internal/model"
+	"time"
+)
+
+// GetCheckInCounts returns how many passes have been checked in at the
+// gates, by gate and by tier, and admissions per zone.
+func GetCheckInCounts(ctx context.Context) (*model.CheckInCounts, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	counts := &model.CheckInCounts{
+		ByGate: make(map[string]int),
+		ByZone: make(map[string]int),
+		ByTier: make(map[string]model.TierCheckIns),
+	}
+
+	tierQuery := `
+	SELECT pt.ticket_title, COUNT(*),
+		SUM(CASE WHEN EXISTS (SELECT 1 FROM checkins c WHERE c.ticket_id = pt.id AND c.zone = '' AND c.result = 'admitted') THEN 1 ELSE 0 END)
+	FROM purchased_tickets pt
+	WHERE pt.status = 'active'
+	GROUP BY pt.ticket_title
+	`
+	err := scanCounts(ctx, tierQuery, func(rows *sql.Rows) error {
+		var (
+			title string
+			tier  model.TierCheckIns
+		)
+		if err := rows.Scan(&title, &tier.Issued, &tier.CheckedIn); err != nil {
+			return err
+		}
+		counts.ByTier[title] = tier
+		counts.Issued += tier.Issued
+		counts.CheckedIn += tier.CheckedIn
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanCounts(ctx, `SELECT gate, COUNT(*) FROM checkins WHERE zone = '' AND result = 'admitted' GROUP BY gate`, func(rows *sql.Rows) error {
+		var (
+			gate string
+			n    int
+		)
+		if err := rows.Scan(&gate, &n); err != nil {
+			return err
+		}
+		counts.ByGate[gate] = n
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanCounts(ctx, `SELECT zone, COUNT(*) FROM checkins WHERE zone != '' AND result = 'admitted' GROUP BY zone`, func(rows *sql.Rows) error {
+		var (
+			zone string
+			n    int
+		)
+		if err := rows.Scan(&zone, &n); err != nil {
+			return err
+		}
+		counts.ByZone[zone] = n
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+func scanCounts(ctx context.Context, query string, scan func(*sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to query check-in counts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return fmt.Errorf("failed to scan check-in counts: %w", err)
+		}
+	}
+	return rows.Err()
+}
+
+// GetCheckInSnapshot returns the current counts and the latest scans, for
+// dashboards starting up or reconnecting to the live stream.
+func GetCheckInSnapshot(ctx context.Context, recent int) (*model.CheckInSnapshot, error) {
+	counts, err := GetCheckInCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &model.CheckInSnapshot{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Counts:      *counts,
+		Recent:      []model.CheckIn{},
+	}
+
+	query := `
+	SELECT c.result, COALESCE(c.ticket_id, 0), COALESCE(u.name, ''), COALESCE(u.email, ''), COALESCE(pt.ticket_title, ''), c.gate, c.zone, c.checked_in_at
+	FROM checkins c
+	LEFT JOIN purchased_tickets pt ON pt.id = c.ticket_id
+	LEFT JOIN users u ON u.id = pt.user_id
+	ORDER BY c.id DESC
+	LIMIT ?
+	`
+	rows, err := db.QueryContext(ctx, query, recent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recent check-ins: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c model.CheckIn
+		if err := rows.Scan(&c.Result, &c.TicketID, &c.Name, &c.Email, &c.TicketTitle, &c.Gate, &c.Zone, &c.CheckedInAt); err != nil {
+			return nil, fmt.Errorf("failed to scan check-in: %w", err)
+		}
+		c.Allowed = c.Result == CheckInAdmitted
+		snapshot.Recent = append(snapshot.Recent, c)
+	}
+	return snapshot, rows.Err()
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"reg/internal/model"
+	"slices"
+	"testing"
+)
+
+// testScanner creates a scanner at the main gate, assigned to zone when it
+// is not empty.
+func testScanner(t *testing.T, zone string) *model.Scanner {
+	t.Helper()
+	scanner, _, err := CreateScanner(context.Background(), "scanner", "Main gate", zone, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scanner
+}
+
+// scan checks code in n times and returns the results.
+func scan(t *testing.T, code string, scanner *model.Scanner, n int) []string {
+	t.Helper()
+	var results []string
+	for range n {
+		checkIn, err := CheckIn(context.Background(), code, *scanner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if checkIn.Allowed != (checkIn.Result == CheckInAdmitted) {
+			t.Errorf("result %s has allowed = %v", checkIn.Result, checkIn.Allowed)
+		}
+		results = append(results, checkIn.Result)
+	}
+	return results
+}
+
+func TestCheckInAdmitsOnceAtTheGate(t *testing.T) {
+	openTestDB(t)
+	ticket := createTestTicket(t, "guest@example.com", "STANDARD")
+	scanner := testScanner(t, "")
+
+	got := scan(t, ticket.UID, scanner, 3)
+	want := []string{CheckInAdmitted, CheckInAlreadyUsed, CheckInAlreadyUsed}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got results %v, want %v", got, want)
+	}
+
+	checkIn, err := CheckIn(context.Background(), ticket.UID, *scanner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkIn.FirstGate != "Main gate" {
+		t.Errorf("got first gate %q, want %q", checkIn.FirstGate, "Main gate")
+	}
+
+	var recorded int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM checkins WHERE scanned_code = ?`, ticket.UID).Scan(&recorded); err != nil {
+		t.Fatal(err)
+	}
+	if recorded != 4 {
+		t.Errorf("got %d recorded scans, want 4", recorded)
+	}
+}
+
+func TestCheckInZones(t *testing.T) {
+	openTestDB(t)
+	premium := createTestTicket(t, "premium@example.com", "PREMIUM")
+	standard := createTestTicket(t, "standard@example.com", "STANDARD")
+
+	// The food carnival admits any number of times, the dinner once
+	if got := scan(t, premium.UID, testScanner(t, "food-carnival"), 2); !slices.Equal(got, []string{CheckInAdmitted, CheckInAdmitted}) {
+		t.Errorf("food carnival: got %v", got)
+	}
+	dinner := testScanner(t, "networking-dinner")
+	if got := scan(t, premium.UID, dinner, 2); !slices.Equal(got, []string{CheckInAdmitted, CheckInAlreadyUsed}) {
+		t.Errorf("dinner: got %v", got)
+	}
+	if got := scan(t, standard.UID, dinner, 1); !slices.Equal(got, []string{CheckInDenied}) {
+		t.Errorf("dinner without the entitlement: got %v", got)
+	}
+}
+
+func TestCheckInCancelledTicket(t *testing.T) {
+	openTestDB(t)
+	ticket := createTestTicket(t, "guest@example.com", "STANDARD")
+	if _, err := CancelTicket(context.Background(), ticket.TicketID); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := scan(t, ticket.UID, testScanner(t, ""), 1); !slices.Equal(got, []string{CheckInRevoked}) {
+		t.Errorf("got %v, want revoked", got)
+	}
+}
+
+func TestCheckInInvalidCodes(t *testing.T) {
+	openTestDB(t)
+	ticket := createTestTicket(t, "guest@example.com", "STANDARD")
+	scanner := testScanner(t, "")
+
+	if got := scan(t, ticket.UID[:len(ticket.UID)-1]+"x", scanner, 1); !slices.Equal(got, []string{CheckInInvalid}) {
+		t.Errorf("forged code: got %v", got)
+	}
+
+	if _, err := issuePassCode(context.Background(), db, int64(ticket.TicketID)); err != nil {
+		t.Fatal(err)
+	}
+	if got := scan(t, ticket.UID, scanner, 1); !slices.Equal(got, []string{CheckInInvalid}) {
+		t.Errorf("replaced code: got %v", got)
+	}
+}
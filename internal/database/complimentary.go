@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+var ErrAlreadyHasTicket = errors.New("user already has a ticket")
+
+// IssueComplimentaryTicket gives a free ticket of the tier to the user with
+// the email, creating the account if needed, and records who issued it and
+// why. It returns ErrAlreadyHasTicket if the user already owns an active
+// ticket and ErrSoldOut if the tier has no free seat, unless overCapacity
+// allows going past its capacity.
+func IssueComplimentaryTicket(ctx context.Context, email, name, ticketTitle, reason, issuedBy string, overCapacity bool) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is not initialized")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	userID, err := findOrCreateUser(ctx, tx, email, name, "")
+	if err != nil {
+		return 0, err
+	}
+
+	var hasTicket bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM purchased_tickets WHERE user_id = ? AND status = 'active')`, userID).Scan(&hasTicket)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check existing tickets: %w", err)
+	}
+	if hasTicket {
+		return 0, ErrAlreadyHasTicket
+	}
+
+	// A seat the user is already holding for the tier becomes their ticket
+	var heldQuantity int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(quantity), 0) FROM ticket_holds
+		WHERE user_id = ? AND ticket_title = ? AND status = 'active' AND txn_id IS NULL
+			AND (expires_at IS NULL OR expires_at > datetime('now'))
+	`, userID, ticketTitle).Scan(&heldQuantity)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch holds: %w", err)
+	}
+
+	if !overCapacity {
+		capacity, sold, held, err := availability(ctx, tx, ticketTitle)
+		if err != nil {
+			return 0, err
+		}
+		if capacity >= 0 && capacity-sold-held < 1-heldQuantity {
+			return 0, ErrSoldOut
+		}
+	}
+
+	insertQuery := `
+		INSERT INTO purchased_tickets (user_id, ticket_title, price, isAccommodation, is_complimentary, issued_by, comp_reason)
+		VALUES (?, ?, 0, FALSE, TRUE, ?, ?)
+	`
+	result, err := tx.ExecContext(ctx, insertQuery, userID, ticketTitle, issuedBy, reason)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add ticket: %w", err)
+	}
+	ticketID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve last insert ID: %w", err)
+	}
+	if _, err := issuePassCode(ctx, tx, ticketID); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE ticket_holds SET status = 'converted' WHERE user_id = ? AND ticket_title = ? AND status = 'active' AND txn_id IS NULL`, userID, ticketTitle)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert hold: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Complimentary %s ticket issued to %s by %s", ticketTitle, email, issuedBy)
+	return int(ticketID), nil
+}
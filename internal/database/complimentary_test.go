@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestIssueComplimentaryTicketCapacity(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		capacity     int
+		holdBy       string
+		overCapacity bool
+		want         error
+	}{
+		{name: "free seat", capacity: 1},
+		{name: "seat held by someone else", capacity: 1, holdBy: "buyer@example.com", want: ErrSoldOut},
+		{name: "seat held by the recipient", capacity: 1, holdBy: "guest@example.com"},
+		{name: "override", capacity: 1, holdBy: "buyer@example.com", overCapacity: true},
+		{name: "uncapped", capacity: -1, holdBy: "buyer@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openTestDB(t)
+			if err := SetTicketCapacity(ctx, "PREMIUM", tt.capacity); err != nil {
+				t.Fatal(err)
+			}
+			if tt.holdBy != "" {
+				if _, err := ReserveTickets(ctx, createTestUser(t, tt.holdBy), "PREMIUM", 1, ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := IssueComplimentaryTicket(ctx, "guest@example.com", "Guest", "PREMIUM", "speaker", "admin", tt.overCapacity)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -27,8 +27,18 @@ func New() {
 		return
 	}
 
-	// Initialize the database connection
-	dbConnection, err := sql.Open("sqlite", dburl)
+	// Initialize the database connection. Background workers write while
+	// handlers do, so every connection waits for the write lock instead of
+	// failing as busy.
+	dsn := dburl
+	if !strings.Contains(dsn, "busy_timeout") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "_pragma=busy_timeout(5000)"
+	}
+	dbConnection, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		log.Fatalf("Failed to open database connection: %v", err)
 	}
@@ -44,6 +54,12 @@ func New() {
 		log.Fatalf("Database migration failed: %v", err)
 	}
 
+	if ids, err := BackfillPassCodes(context.Background()); err != nil {
+		log.Fatalf("Failed to backfill pass codes: %v", err)
+	} else if len(ids) > 0 {
+		log.Printf("Issued pass codes for %d existing tickets, their passes have to be sent again: %v", len(ids), ids)
+	}
+
 	log.Println("Database successfully initialized")
 }
 
@@ -190,11 +206,364 @@ func Migrate() error {
 	
 	`
 
-	// INSERT INTO tickets (name, description, price) VALUES
-	// 	('STANDARD', 'All Speaker Sessions, Startup Fair, Food Carnival', -1),
-	// 	('VALUE FOR MONEY', 'All Speaker Sessions, Startup Fair, Food Carniva, Fetching Fortune Spectator', 399),
-	// 	('PREMIUM',  'All Speaker Sessions, Startup Fair, Food Carniva, Fetching Fortune Spectator, Networking Dinner, Accommodation, (2 Days 1 Night)', 999);
-	// `
+	createCatalogQuery := `
+	CREATE TABLE IF NOT EXISTS tickets (
+		name TEXT PRIMARY KEY,
+		description TEXT NOT NULL,
+		price REAL NOT NULL
+	);
+
+	INSERT OR IGNORE INTO tickets (name, description, price) VALUES
+		('STANDARD', 'All Speaker Sessions, Startup Fair, Food Carnival', -1),
+		('VALUE FOR MONEY', 'All Speaker Sessions, Startup Fair, Food Carniva, Fetching Fortune Spectator', 399),
+		('PREMIUM',  'All Speaker Sessions, Startup Fair, Food Carniva, Fetching Fortune Spectator, Networking Dinner, Accommodation, (2 Days 1 Night)', 999);
+	`
+
+	createGroupQuery := `
+	CREATE TABLE IF NOT EXISTS group_orders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		payer_id INTEGER NOT NULL,
+		txn_id TEXT NOT NULL UNIQUE,
+		ticket_title TEXT NOT NULL,
+		isAccommodation BOOLEAN DEFAULT FALSE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (payer_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (txn_id) REFERENCES transactions(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS group_order_members (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_id INTEGER NOT NULL,
+		email TEXT NOT NULL,
+		name TEXT NOT NULL,
+		contact_number TEXT NOT NULL DEFAULT '',
+		ticket_id INTEGER,
+		UNIQUE (group_id, email),
+		FOREIGN KEY (group_id) REFERENCES group_orders(id) ON DELETE CASCADE,
+		FOREIGN KEY (ticket_id) REFERENCES purchased_tickets(id)
+	);
+	`
+
+	createInventoryQuery := `
+	CREATE TABLE IF NOT EXISTS ticket_holds (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		ticket_title TEXT NOT NULL,
+		quantity INTEGER NOT NULL DEFAULT 1,
+		txn_id TEXT,
+		status TEXT NOT NULL DEFAULT 'active',
+		expires_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_ticket_holds_title ON ticket_holds(ticket_title, status);
+
+	CREATE TABLE IF NOT EXISTS waitlist (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		ticket_title TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'waiting',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		promoted_at DATETIME,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_waitlist_title ON waitlist(ticket_title, status);
+	`
+
+	createPricingQuery := `
+	CREATE TABLE IF NOT EXISTS pricing_phases (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ticket_title TEXT NOT NULL,
+		name TEXT NOT NULL,
+		price REAL NOT NULL,
+		starts_at DATETIME,
+		ends_at DATETIME,
+		max_quantity INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (ticket_title, name),
+		FOREIGN KEY (ticket_title) REFERENCES tickets(name) ON DELETE CASCADE
+	);
+	`
+
+	createCheckinQuery := `
+	CREATE TABLE IF NOT EXISTS scanners (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		gate TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		is_active BOOLEAN DEFAULT TRUE,
+		created_by TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS checkins (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ticket_id INTEGER,
+		scanner_id INTEGER NOT NULL,
+		gate TEXT NOT NULL,
+		result TEXT NOT NULL,
+		scanned_code TEXT NOT NULL,
+		checked_in_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (ticket_id) REFERENCES purchased_tickets(id) ON DELETE CASCADE,
+		FOREIGN KEY (scanner_id) REFERENCES scanners(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_checkins_ticket ON checkins(ticket_id, result);
+	`
+
+	// What each tier includes, scanners assigned to a zone admit against these.
+	// Meals and nights can only be used once.
+	createEntitlementQuery := `
+	CREATE TABLE IF NOT EXISTS entitlements (
+		ticket_title TEXT NOT NULL,
+		kind TEXT NOT NULL CHECK (kind IN ('zone', 'session', 'meal', 'night')),
+		code TEXT NOT NULL,
+		name TEXT NOT NULL,
+		max_uses INTEGER,
+		PRIMARY KEY (ticket_title, code),
+		FOREIGN KEY (ticket_title) REFERENCES tickets(name) ON DELETE CASCADE
+	);
+
+	INSERT OR IGNORE INTO entitlements (ticket_title, kind, code, name, max_uses) VALUES
+		('STANDARD', 'session', 'speaker-sessions', 'All Speaker Sessions', NULL),
+		('STANDARD', 'zone', 'startup-fair', 'Startup Fair', NULL),
+		('STANDARD', 'zone', 'food-carnival', 'Food Carnival', NULL),
+		('VALUE FOR MONEY', 'session', 'speaker-sessions', 'All Speaker Sessions', NULL),
+		('VALUE FOR MONEY', 'zone', 'startup-fair', 'Startup Fair', NULL),
+		('VALUE FOR MONEY', 'zone', 'food-carnival', 'Food Carnival', NULL),
+		('VALUE FOR MONEY', 'session', 'fetching-fortune', 'Fetching Fortune Spectator', NULL),
+		('PREMIUM', 'session', 'speaker-sessions', 'All Speaker Sessions', NULL),
+		('PREMIUM', 'zone', 'startup-fair', 'Startup Fair', NULL),
+		('PREMIUM', 'zone', 'food-carnival', 'Food Carnival', NULL),
+		('PREMIUM', 'session', 'fetching-fortune', 'Fetching Fortune Spectator', NULL),
+		('PREMIUM', 'meal', 'networking-dinner', 'Networking Dinner', 1),
+		('PREMIUM', 'night', 'accommodation', 'Accommodation (1 Night)', 1);
+	`
+
+	// When the event days and the sessions of entitlements take place, in UTC.
+	// Calendars and wallet passes are built from it.
+	createScheduleQuery := `
+	CREATE TABLE IF NOT EXISTS schedule (
+		code TEXT PRIMARY KEY,
+		kind TEXT NOT NULL CHECK (kind IN ('day', 'session')),
+		summary TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		starts_at DATETIME NOT NULL,
+		ends_at DATETIME NOT NULL
+	);
+
+	INSERT OR IGNORE INTO schedule (code, kind, summary, description, starts_at, ends_at) VALUES
+		('day-1', 'day', 'E-Summit 2025 - Day 1', 'Day 1 of E-Summit 2025 by E-Cell IIT Hyderabad. Bring your pass, it is scanned at the gate.', '2025-02-08 03:30:00', '2025-02-08 12:30:00'),
+		('day-2', 'day', 'E-Summit 2025 - Day 2', 'Day 2 of E-Summit 2025 by E-Cell IIT Hyderabad. Bring your pass, it is scanned at the gate.', '2025-02-09 03:30:00', '2025-02-09 12:30:00'),
+		('networking-dinner', 'session', 'E-Summit 2025 - Networking Dinner', 'Dinner with speakers, founders and investors, included with your pass. Your pass is scanned at the entrance.', '2025-02-08 14:00:00', '2025-02-08 16:30:00');
+	`
+
+	// Columns added to tables that already exist in deployed databases
+	columns := []struct {
+		table, column, definition string
+	}{
+		{"transactions", "type", "TEXT DEFAULT 'purchase'"},
+		{"transactions", "ticket_id", "INTEGER"},
+		{"tickets", "capacity", "INTEGER NOT NULL DEFAULT -1"},
+		{"purchased_tickets", "status", "TEXT NOT NULL DEFAULT 'active'"},
+		{"transactions", "pricing_phase", "TEXT DEFAULT ''"},
+		{"purchased_tickets", "is_complimentary", "BOOLEAN DEFAULT FALSE"},
+		{"purchased_tickets", "issued_by", "TEXT DEFAULT ''"},
+		{"purchased_tickets", "comp_reason", "TEXT DEFAULT ''"},
+		{"purchased_tickets", "pass_code", "TEXT"},
+		{"purchased_tickets", "pass_code_backfilled", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"scanners", "zone", "TEXT NOT NULL DEFAULT ''"},
+		{"checkins", "zone", "TEXT NOT NULL DEFAULT ''"},
+		{"checkins", "client_id", "TEXT"},
+		{"checkins", "synced_at", "DATETIME"},
+		{"users", "gender", "TEXT NOT NULL DEFAULT ''"},
+		{"users", "email_announcements", "BOOLEAN NOT NULL DEFAULT TRUE"},
+		{"users", "email_reminders", "BOOLEAN NOT NULL DEFAULT TRUE"},
+		{"campaigns", "category", "TEXT NOT NULL DEFAULT 'announcements'"},
+		{"users", "email_undeliverable", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"users", "email_bounced_at", "DATETIME"},
+		{"users", "email_bounce_reason", "TEXT NOT NULL DEFAULT ''"},
+		{"email_outbox", "user_id", "INTEGER REFERENCES users(id) ON DELETE SET NULL"},
+		{"email_outbox", "template", "TEXT NOT NULL DEFAULT ''"},
+		{"email_outbox", "resent_from", "INTEGER"},
+	}
+
+	createDispatchQuery := `
+	CREATE TABLE IF NOT EXISTS dispatch_jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		started_by TEXT DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'running',
+		total INTEGER NOT NULL DEFAULT 0,
+		sent INTEGER NOT NULL DEFAULT 0,
+		failed INTEGER NOT NULL DEFAULT 0,
+		started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		finished_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS pass_dispatch (
+		ticket_id INTEGER PRIMARY KEY,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT DEFAULT '',
+		job_id INTEGER,
+		sent_at DATETIME,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (ticket_id) REFERENCES purchased_tickets(id) ON DELETE CASCADE,
+		FOREIGN KEY (job_id) REFERENCES dispatch_jobs(id)
+	);
+	`
+
+	// Passes handed from one attendee to another. The code the pass had
+	// before is kept so scanning it reports the pass as revoked.
+	createTransferQuery := `
+	CREATE TABLE IF NOT EXISTS pass_transfers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ticket_id INTEGER NOT NULL,
+		from_user_id INTEGER NOT NULL,
+		to_email TEXT NOT NULL,
+		to_name TEXT NOT NULL,
+		to_user_id INTEGER,
+		token_hash TEXT NOT NULL UNIQUE,
+		status TEXT NOT NULL DEFAULT 'pending',
+		old_pass_code TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME NOT NULL,
+		resolved_at DATETIME,
+		FOREIGN KEY (ticket_id) REFERENCES purchased_tickets(id) ON DELETE CASCADE,
+		FOREIGN KEY (from_user_id) REFERENCES users(id),
+		FOREIGN KEY (to_user_id) REFERENCES users(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_pass_transfers_ticket ON pass_transfers(ticket_id, status);
+	CREATE INDEX IF NOT EXISTS idx_pass_transfers_old_code ON pass_transfers(old_pass_code);
+	`
+
+	// Hostel rooms for attendees whose pass includes accommodation. A bed is
+	// taken by an allocation until its holder checks out at the hostel desk.
+	createAccommodationQuery := `
+	CREATE TABLE IF NOT EXISTS hostels (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		gender TEXT NOT NULL DEFAULT 'any' CHECK (gender IN ('male', 'female', 'any')),
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS rooms (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		hostel_id INTEGER NOT NULL,
+		number TEXT NOT NULL,
+		capacity INTEGER NOT NULL CHECK (capacity > 0),
+		UNIQUE (hostel_id, number),
+		FOREIGN KEY (hostel_id) REFERENCES hostels(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS room_allocations (
+		ticket_id INTEGER PRIMARY KEY,
+		room_id INTEGER NOT NULL,
+		status TEXT NOT NULL DEFAULT 'allocated',
+		allocated_by TEXT DEFAULT '',
+		allocated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		checked_in_at DATETIME,
+		checked_out_at DATETIME,
+		FOREIGN KEY (ticket_id) REFERENCES purchased_tickets(id) ON DELETE CASCADE,
+		FOREIGN KEY (room_id) REFERENCES rooms(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_room_allocations_room ON room_allocations(room_id, status);
+	`
+
+	// Every outgoing email is stored here as a complete message and delivered
+	// by the outbox workers. ticket_id links pass emails to pass_dispatch.
+	createOutboxQuery := `
+	CREATE TABLE IF NOT EXISTS email_outbox (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		sender TEXT NOT NULL,
+		recipients TEXT NOT NULL,
+		to_addr TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		message BLOB NOT NULL,
+		ticket_id INTEGER,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT DEFAULT '',
+		next_attempt_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		sent_at DATETIME,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (ticket_id) REFERENCES purchased_tickets(id) ON DELETE SET NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_email_outbox_due ON email_outbox(status, next_attempt_at);
+	`
+
+	// A campaign mails one of the campaign templates to a segment of users.
+	// Its recipients are fixed when sending starts and are queued in the
+	// outbox at the campaign's rate.
+	createCampaignQuery := `
+	CREATE TABLE IF NOT EXISTS campaigns (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		template TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		heading TEXT NOT NULL DEFAULT '',
+		message TEXT NOT NULL DEFAULT '',
+		action_text TEXT NOT NULL DEFAULT '',
+		action_url TEXT NOT NULL DEFAULT '',
+		segment TEXT NOT NULL DEFAULT '{}',
+		rate_per_minute INTEGER NOT NULL DEFAULT 60,
+		status TEXT NOT NULL DEFAULT 'draft',
+		created_by TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		started_at DATETIME,
+		finished_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS campaign_recipients (
+		campaign_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		email TEXT NOT NULL,
+		name TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		outbox_id INTEGER,
+		error TEXT DEFAULT '',
+		queued_at DATETIME,
+		PRIMARY KEY (campaign_id, user_id),
+		FOREIGN KEY (campaign_id) REFERENCES campaigns(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (outbox_id) REFERENCES email_outbox(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_campaign_recipients_status ON campaign_recipients(campaign_id, status);
+	`
+
+	// Failed recipients read from the delivery status notifications in the
+	// bounce mailbox. A notification is recorded once per recipient, so
+	// rescanning the mailbox does not count it again.
+	createBounceQuery := `
+	CREATE TABLE IF NOT EXISTS email_bounces (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		message_key TEXT NOT NULL,
+		email TEXT NOT NULL,
+		user_id INTEGER,
+		status TEXT NOT NULL DEFAULT '',
+		diagnostic TEXT NOT NULL DEFAULT '',
+		received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (message_key, email),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_email_bounces_email ON email_bounces(email);
+	`
+
+	// Indexes on columns from the list above
+	createIndexQuery := `
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_purchased_tickets_pass_code ON purchased_tickets(pass_code);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_checkins_client ON checkins(scanner_id, client_id) WHERE client_id IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS idx_email_outbox_user ON email_outbox(user_id);
+	CREATE INDEX IF NOT EXISTS idx_email_outbox_to ON email_outbox(to_addr);
+	`
 
 	// Execute the queries
 	_, err := db.Exec(createRegistrationsTableQuery)
@@ -207,10 +576,123 @@ func Migrate() error {
 		return fmt.Errorf("failed to create otps table: %w", err)
 	}
 
+	_, err = db.Exec(createCatalogQuery)
+	if err != nil {
+		return fmt.Errorf("failed to create tickets table: %w", err)
+	}
+
+	_, err = db.Exec(createGroupQuery)
+	if err != nil {
+		return fmt.Errorf("failed to create group order tables: %w", err)
+	}
+
+	_, err = db.Exec(createInventoryQuery)
+	if err != nil {
+		return fmt.Errorf("failed to create inventory tables: %w", err)
+	}
+
+	_, err = db.Exec(createPricingQuery)
+	if err != nil {
+		return fmt.Errorf("failed to create pricing_phases table: %w", err)
+	}
+
+	_, err = db.Exec(createCheckinQuery)
+	if err != nil {
+		return fmt.Errorf("failed to create check-in tables: %w", err)
+	}
+
+	_, err = db.Exec(createEntitlementQuery)
+	if err != nil {
+		return fmt.Errorf("failed to create entitlements table: %w", err)
+	}
+
+	_, err = db.Exec(createScheduleQuery)
+	if err != nil {
+		return fmt.Errorf("failed to create schedule table: %w", err)
+	}
+
+	_, err = db.Exec(createDispatchQuery)
+	if err != nil {
+		return fmt.Errorf("failed to create dispatch tables: %w", err)
+	}
+
+	_, err = db.Exec(createTransferQuery)
+	if err != nil {
+		return fmt.Errorf("failed to create pass_transfers table: %w", err)
+	}
+
+	_, err = db.Exec(createAccommodationQuery)
+	if err != nil {
+		return fmt.Errorf("failed to create accommodation tables: %w", err)
+	}
+
+	_, err = db.Exec(createOutboxQuery)
+	if err != nil {
+		return fmt.Errorf("failed to create email_outbox table: %w", err)
+	}
+
+	_, err = db.Exec(createCampaignQuery)
+	if err != nil {
+		return fmt.Errorf("failed to create campaign tables: %w", err)
+	}
+
+	_, err = db.Exec(createBounceQuery)
+	if err != nil {
+		return fmt.Errorf("failed to create email_bounces table: %w", err)
+	}
+
+	for _, col := range columns {
+		if err := addColumn(col.table, col.column, col.definition); err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec(createIndexQuery)
+	if err != nil {
+		return fmt.Errorf("failed to create indexes: %w", err)
+	}
+
 	log.Println("Database migration completed successfully")
 	return nil
 }
 
+// addColumn adds a column to an existing table unless it is already present.
+func addColumn(table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+
+	exists := false
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan columns of %s: %w", table, err)
+		}
+		if name == column {
+			exists = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
 // CreateRegistration inserts a new registration into the database.
 func CreateRegistration(ctx context.Context, data model.RegistrationData) (int64, error) {
 	if db == nil {
@@ -375,96 +857,3 @@ func MarkRegistrationAsPushed(ctx context.Context, data []model.RegistrationData
 
 	return nil
 }
-
-func GetIDsForPasses() []model.UserTicket {
-	// List of email addresses extracted from the logs
-	emailedUsers := []string{
-		"kaizeltech@gmail.com",
-		"nrhitesh111@gmail.com",
-		"gyaani2004@gmail.com",
-		"Jaswanthkumar431@gmail.com",
-		"mailforpraveen999@gmail.com",
-		"sreeja.darlz@gmail.com",
-		"tejavishnu2000@gmail.com",
-		"deepak08925@gmail.com",
-		"dasarirahulpatel.drp@gmail.com",
-		"kartheekdama2004@gmail.com",
-		"varshitha03@gmail.com",
-		"manojkiranb98@gmail.com",
-		"mukeshchowdar777@gmail.com",
-		"ravitejareddy875@gmail.com",
-		"shivakotagiri532@gmail.com",
-		"kevinpaul468@gmail.com",
-		"akshayabejgum05@gmail.com",
-		"Kaizeltech@gmail.com",
-		"nishnabandari@gmail.com",
-		"arkalavarshitha37@gmail.com",
-		"likhitharambha@gmail.com",
-		"mechinenil@gmail.com",
-		"divyareddyavula17@gmail.com",
-		"noothisrimulya@gmail.com",
-		"vijayintelli72@gmail.com",
-		"hajrafatima1212@gmail.com",
-		"imabdullah5978@gmail.com",
-		"mdsaif6304@gmail.com",
-		"vijaysuru620@gmail.com",
-		"palthisaketh93@gmail.com",
-		"rajnikita05@gmail.com",
-		"pranavpolawar123@gmail.com",
-		"dasarianjani1@gmail.com",
-		"anjali.gadikhana16@gmail.com",
-		"nagirimihira1960@gmail.com",
-		"gorantlasreeja589@gmail.com",
-		"rojaberi2005@gmail.com",
-		"swathi8379t@gmail.com",
-		"ravi2182003@gmail.com",
-		"sathvikrepala30@gmail.com",
-		"nalajalaabhi2004@gmail.com",
-		"22r01a67g0@cmrithyderabad.edu.in",
-		"charangoud3333@gmail.com",
-		"varunsaivarma8@gmail.com",
-		"shivanisweety102@gmail.com",
-		"nenavathkalyan300@gmail.com",
-		"patnammahesh75@gmail.com",
-		"sarayusiri15@gmail.com",
-		"22r01a67b2@gmail.com",
-		"mehulagarwal11111@gmail.com",
-		"chaturvediprabhu939@gmail.com",
-	}
-
-	// Construct the NOT IN clause for the SQL query
-	notInClause := "'" + strings.Join(emailedUsers, "', '") + "'"
-
-	query := fmt.Sprintf(`
-		SELECT u.id, u.name, u.email, pt.ticket_title 
-		FROM purchased_tickets pt
-		JOIN users u ON pt.user_id = u.id
-		WHERE u.email NOT IN (%s)
-		ORDER BY pt.id
-	`, notInClause)
-
-	rows, err := db.Query(query)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer rows.Close()
-
-	var userTickets []model.UserTicket
-
-	for rows.Next() {
-		var ut model.UserTicket
-		if err := rows.Scan(&ut.ID, &ut.Name, &ut.Email, &ut.TicketTitle); err != nil {
-			log.Fatal(err)
-		}
-		trimmedTitle := strings.ReplaceAll(strings.TrimSpace(ut.TicketTitle), " ", "_")
-		ut.UID = fmt.Sprintf("%d_%s_%s_GUEST", ut.ID, strings.ToUpper(trimmedTitle), strings.ToLower(ut.Email))
-		userTickets = append(userTickets, ut)
-	}
-
-	// Check for errors from iteration
-	if err = rows.Err(); err != nil {
-		log.Fatal(err)
-	}
-
-	return userTickets
-}
\ No newline at end of file
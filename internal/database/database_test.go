@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reg/internal/model"
+	"sync/atomic"
+	"testing"
+)
+
+var testDBs atomic.Int64
+
+// openTestDB points the package at a fresh migrated in-memory database for
+// the duration of a test.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	t.Setenv("PASS_SECRET", "test-pass-secret")
+
+	// Connections of the pool have to share one database, and it must stay
+	// distinct from the databases of other tests.
+	dsn := fmt.Sprintf("file:test%d?mode=memory&cache=shared&_pragma=busy_timeout(5000)", testDBs.Add(1))
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	previous := db
+	db = conn
+	t.Cleanup(func() {
+		conn.Close()
+		db = previous
+	})
+
+	if err := Migrate(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+}
+
+// createTestUser adds a user and returns their id.
+func createTestUser(t *testing.T, email string) int {
+	t.Helper()
+	id, err := CreateUser(context.Background(), model.User{Email: email, Name: email, ContactNumber: "9999999999"})
+	if err != nil {
+		t.Fatalf("failed to create user %s: %v", email, err)
+	}
+	return int(id)
+}
+
+// createTestTicket adds a user holding an active ticket of a tier and
+// returns the ticket.
+func createTestTicket(t *testing.T, email, tier string) *model.UserTicket {
+	t.Helper()
+	userID := createTestUser(t, email)
+	if err := AddBasicTickets(userID, tier); err != nil {
+		t.Fatalf("failed to add ticket for %s: %v", email, err)
+	}
+	purchased, err := GetUserPurchasedTicket(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("failed to fetch ticket of %s: %v", email, err)
+	}
+	ticket, err := GetUserTicket(context.Background(), purchased.ID)
+	if err != nil {
+		t.Fatalf("failed to fetch ticket of %s: %v", email, err)
+	}
+	return ticket
+}
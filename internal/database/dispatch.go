@@ -0,0 +1,282 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reg/internal/model"
+	"strings"
+)
+
+// Pass dispatch statuses
+const (
+	DispatchSending = "sending"
+	DispatchQueued  = "queued"
+	DispatchSent    = "sent"
+	DispatchFailed  = "failed"
+)
+
+// Dispatch job statuses
+const (
+	JobRunning     = "running"
+	JobCompleted   = "completed"
+	JobInterrupted = "interrupted"
+)
+
+var ErrJobNotFound = errors.New("dispatch job not found")
+
+// MarkPassesSent records the passes of the users with the given emails as
+// sent, for passes mailed before dispatch was tracked, so dispatch jobs do
+// not mail them again. Passes that already have a dispatch status keep it,
+// and backfilled tickets are skipped: what was mailed to them is the old
+// barcode, the code they hold now still has to be sent. It returns the
+// number of passes marked.
+func MarkPassesSent(ctx context.Context, emails []string) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is not initialized")
+	}
+	if len(emails) == 0 {
+		return 0, nil
+	}
+
+	args := make([]any, len(emails))
+	for i, email := range emails {
+		args[i] = strings.ToLower(strings.TrimSpace(email))
+	}
+	query := `
+	INSERT OR IGNORE INTO pass_dispatch (ticket_id, status, attempts, sent_at)
+	SELECT pt.id, 'sent', 1, CURRENT_TIMESTAMP
+	FROM purchased_tickets pt
+	JOIN users u ON u.id = pt.user_id
+	WHERE pt.status = 'active' AND pt.pass_code_backfilled = FALSE AND LOWER(u.email) IN (?` + strings.Repeat(", ?", len(args)-1) + `)
+	`
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark passes as sent: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// RecordPassDispatch stores the outcome of queueing a ticket's pass in the
+// outbox. jobID is nil for passes mailed outside a dispatch job.
+func RecordPassDispatch(ctx context.Context, ticketID int, jobID *int64, sendErr error) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	status, lastError := DispatchQueued, ""
+	if sendErr != nil {
+		status, lastError = DispatchFailed, sendErr.Error()
+	}
+
+	query := `
+	INSERT INTO pass_dispatch (ticket_id, status, attempts, last_error, job_id, updated_at)
+	VALUES (?, ?, 1, ?, ?, CURRENT_TIMESTAMP)
+	ON CONFLICT (ticket_id) DO UPDATE SET
+		status = excluded.status,
+		attempts = pass_dispatch.attempts + 1,
+		last_error = excluded.last_error,
+		job_id = COALESCE(excluded.job_id, pass_dispatch.job_id),
+		updated_at = CURRENT_TIMESTAMP
+	`
+	if _, err := db.ExecContext(ctx, query, ticketID, status, lastError, jobID); err != nil {
+		return fmt.Errorf("failed to record pass dispatch: %w", err)
+	}
+	return nil
+}
+
+// RecordPassDelivery stores whether the outbox delivered a queued pass. A
+// failed pass is picked up again by the next dispatch job.
+func RecordPassDelivery(ctx context.Context, ticketID int, sendErr error) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `UPDATE pass_dispatch SET status = 'sent', last_error = '', sent_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE ticket_id = ?`
+	args := []any{ticketID}
+	if sendErr != nil {
+		query = `UPDATE pass_dispatch SET status = 'failed', last_error = ?, updated_at = CURRENT_TIMESTAMP WHERE ticket_id = ?`
+		args = []any{sendErr.Error(), ticketID}
+	}
+	if _, err := db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to record pass delivery: %w", err)
+	}
+	return nil
+}
+
+// passesToDispatchQuery selects active tickets whose pass has not been sent
+// or queued and that the given job has not tried yet.
+const passesToDispatchQuery = `
+	FROM purchased_tickets pt
+	JOIN users u ON u.id = pt.user_id
+	LEFT JOIN pass_dispatch d ON d.ticket_id = pt.id
+	WHERE pt.status = 'active'
+		AND (d.status IS NULL OR d.status NOT IN ('sent', 'queued'))
+		AND (d.job_id IS NULL OR d.job_id != ?)
+`
+
+// CountPassesToDispatch returns how many passes are waiting to be sent.
+func CountPassesToDispatch(ctx context.Context) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is not initialized")
+	}
+
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) `+passesToDispatchQuery, 0).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count passes to dispatch: %w", err)
+	}
+	return n, nil
+}
+
+// ClaimPassesToDispatch marks up to limit unsent passes as being sent by a
+// job and returns them.
+func ClaimPassesToDispatch(ctx context.Context, jobID int64, limit int) ([]model.UserTicket, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `SELECT u.id, pt.id, u.name, u.email, pt.ticket_title, COALESCE(pt.pass_code, ''), pt.status ` + passesToDispatchQuery + ` ORDER BY pt.id LIMIT ?`
+	rows, err := db.QueryContext(ctx, query, jobID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query passes to dispatch: %w", err)
+	}
+
+	var tickets []model.UserTicket
+	for rows.Next() {
+		var ut model.UserTicket
+		if err := rows.Scan(&ut.ID, &ut.TicketID, &ut.Name, &ut.Email, &ut.TicketTitle, &ut.UID, &ut.Status); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan pass to dispatch: %w", err)
+		}
+		tickets = append(tickets, ut)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, ut := range tickets {
+		query := `
+		INSERT INTO pass_dispatch (ticket_id, status, job_id) VALUES (?, 'sending', ?)
+		ON CONFLICT (ticket_id) DO UPDATE SET status = 'sending', job_id = excluded.job_id, updated_at = CURRENT_TIMESTAMP
+		`
+		if _, err := db.ExecContext(ctx, query, ut.TicketID, jobID); err != nil {
+			return nil, fmt.Errorf("failed to claim pass: %w", err)
+		}
+	}
+	return tickets, nil
+}
+
+// CreateDispatchJob starts tracking a dispatch job.
+func CreateDispatchJob(ctx context.Context, startedBy string, total int) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is not initialized")
+	}
+
+	result, err := db.ExecContext(ctx, `INSERT INTO dispatch_jobs (started_by, total) VALUES (?, ?)`, startedBy, total)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create dispatch job: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// UpdateDispatchJob records the progress of a job, finishing it when the
+// status is no longer running.
+func UpdateDispatchJob(ctx context.Context, id int64, sent, failed int, status string) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	UPDATE dispatch_jobs
+	SET sent = ?, failed = ?, status = ?, total = MAX(total, ? + ?),
+		finished_at = CASE WHEN ? = 'running' THEN NULL ELSE CURRENT_TIMESTAMP END
+	WHERE id = ?
+	`
+	if _, err := db.ExecContext(ctx, query, sent, failed, status, sent, failed, status, id); err != nil {
+		return fmt.Errorf("failed to update dispatch job: %w", err)
+	}
+	return nil
+}
+
+// InterruptDispatchJobs closes jobs left running by a previous process and
+// marks the passes they were sending as failed, so the next job retries
+// them.
+func InterruptDispatchJobs(ctx context.Context) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	if _, err := db.ExecContext(ctx, `UPDATE pass_dispatch SET status = 'failed', last_error = 'interrupted', updated_at = CURRENT_TIMESTAMP WHERE status = 'sending'`); err != nil {
+		return fmt.Errorf("failed to reset pass dispatch: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE dispatch_jobs SET status = 'interrupted', finished_at = CURRENT_TIMESTAMP WHERE status = 'running'`); err != nil {
+		return fmt.Errorf("failed to interrupt dispatch jobs: %w", err)
+	}
+	return nil
+}
+
+// GetDispatchJob returns a dispatch job, or the latest one when id is 0.
+func GetDispatchJob(ctx context.Context, id int64) (*model.DispatchJob, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	SELECT id, started_by, status, total, sent, failed, started_at, finished_at
+	FROM dispatch_jobs
+	WHERE ? = 0 OR id = ?
+	ORDER BY id DESC
+	LIMIT 1
+	`
+	var (
+		job      model.DispatchJob
+		finished sql.NullString
+	)
+	err := db.QueryRowContext(ctx, query, id, id).Scan(&job.ID, &job.StartedBy, &job.Status, &job.Total, &job.Sent, &job.Failed, &job.StartedAt, &finished)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch dispatch job: %w", err)
+	}
+	if finished.Valid {
+		job.FinishedAt = &finished.String
+	}
+	return &job, nil
+}
+
+// GetDispatchSummary counts active tickets by the status of their pass,
+// "pending" for passes never sent.
+func GetDispatchSummary(ctx context.Context) (map[string]int, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	SELECT COALESCE(d.status, 'pending'), COUNT(*)
+	FROM purchased_tickets pt
+	LEFT JOIN pass_dispatch d ON d.ticket_id = pt.id
+	WHERE pt.status = 'active'
+	GROUP BY 1
+	`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dispatch summary: %w", err)
+	}
+	defer rows.Close()
+
+	summary := map[string]int{"pending": 0, DispatchSending: 0, DispatchQueued: 0, DispatchSent: 0, DispatchFailed: 0}
+	for rows.Next() {
+		var (
+			status string
+			n      int
+		)
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan dispatch summary: %w", err)
+		}
+		summary[status] = n
+	}
+	return summary, rows.Err()
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reg/internal/model"
+)
+
+var ErrEntitlementNotFound = errors.New("entitlement not found")
+
+// Tickets bought with the accommodation add-on get every night entitlement
+// on top of what their tier includes.
+const ticketEntitlementsQuery = `
+	SELECT e.ticket_title, e.kind, e.code, e.name, e.max_uses
+	FROM entitlements e
+	JOIN purchased_tickets pt ON pt.id = ?
+	WHERE (e.ticket_title = pt.ticket_title OR (e.kind = 'night' AND pt.isAccommodation = TRUE))
+`
+
+func scanEntitlements(rows *sql.Rows) ([]model.Entitlement, error) {
+	defer rows.Close()
+
+	entitlements := []model.Entitlement{}
+	for rows.Next() {
+		var (
+			e       model.Entitlement
+			maxUses sql.NullInt64
+		)
+		if err := rows.Scan(&e.TicketTitle, &e.Kind, &e.Code, &e.Name, &maxUses); err != nil {
+			return nil, fmt.Errorf("failed to scan entitlement: %w", err)
+		}
+		if maxUses.Valid {
+			n := int(maxUses.Int64)
+			e.MaxUses = &n
+		}
+		entitlements = append(entitlements, e)
+	}
+	return entitlements, rows.Err()
+}
+
+// GetEntitlements lists the entitlements of a tier, or of every tier when
+// title is empty.
+func GetEntitlements(ctx context.Context, title string) ([]model.Entitlement, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT ticket_title, kind, code, name, max_uses FROM entitlements WHERE ? = '' OR ticket_title = ? ORDER BY ticket_title, kind, code`, title, title)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entitlements: %w", err)
+	}
+	return scanEntitlements(rows)
+}
+
+// GetTicketEntitlements lists everything a purchased ticket includes.
+func GetTicketEntitlements(ctx context.Context, ticketID int) ([]model.Entitlement, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	rows, err := db.QueryContext(ctx, ticketEntitlementsQuery+` ORDER BY e.kind, e.code`, ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entitlements: %w", err)
+	}
+	entitlements, err := scanEntitlements(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// An add-on night can also be listed under another tier, keep one per code
+	unique := entitlements[:0]
+	seen := make(map[string]bool)
+	for _, e := range entitlements {
+		if !seen[e.Code] {
+			seen[e.Code] = true
+			unique = append(unique, e)
+		}
+	}
+	return unique, nil
+}
+
+func getTicketEntitlement(ctx context.Context, ticketID int, code string) (*model.Entitlement, error) {
+	rows, err := db.QueryContext(ctx, ticketEntitlementsQuery+` AND e.code = ? LIMIT 1`, ticketID, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entitlements: %w", err)
+	}
+	entitlements, err := scanEntitlements(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(entitlements) == 0 {
+		return nil, ErrEntitlementNotFound
+	}
+	return &entitlements[0], nil
+}
+
+// SetEntitlement adds an entitlement to a tier or updates it.
+func SetEntitlement(ctx context.Context, e model.Entitlement) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	if _, err := GetCatalogTicket(ctx, e.TicketTitle); err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO entitlements (ticket_title, kind, code, name, max_uses) VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT (ticket_title, code) DO UPDATE SET kind = excluded.kind, name = excluded.name, max_uses = excluded.max_uses
+	`
+	if _, err := db.ExecContext(ctx, query, e.TicketTitle, e.Kind, e.Code, e.Name, e.MaxUses); err != nil {
+		return fmt.Errorf("failed to save entitlement: %w", err)
+	}
+	return nil
+}
+
+// DeleteEntitlement removes an entitlement from a tier.
+func DeleteEntitlement(ctx context.Context, title, code string) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	result, err := db.ExecContext(ctx, `DELETE FROM entitlements WHERE ticket_title = ? AND code = ?`, title, code)
+	if err != nil {
+		return fmt.Errorf("failed to delete entitlement: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrEntitlementNotFound
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"reg/internal/model"
+)
+
+// CreateGroupOrder stores a pending group transaction paid by one user on
+// behalf of every member. It returns -1 if the transaction id is taken.
+func CreateGroupOrder(ctx context.Context, txnId string, payerID int, amount float64, ticketTitle string, isAccommodation bool, pricingPhase string, members []model.GroupMember) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is not initialized")
+	}
+
+	var exists int
+	err := db.QueryRowContext(ctx, `SELECT 1 FROM transactions WHERE id = ?`, txnId).Scan(&exists)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	if exists == 1 {
+		return -1, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO transactions (id, user_id, amount, ticket_title, isAccommodation, type, pricing_phase) VALUES (?, ?, ?, ?, ?, 'group', ?)`, txnId, payerID, amount, ticketTitle, isAccommodation, pricingPhase)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert transaction: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO group_orders (payer_id, txn_id, ticket_title, isAccommodation) VALUES (?, ?, ?, ?)`, payerID, txnId, ticketTitle, isAccommodation)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert group order: %w", err)
+	}
+	groupID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve last insert ID: %w", err)
+	}
+
+	for _, m := range members {
+		_, err := tx.ExecContext(ctx, `INSERT INTO group_order_members (group_id, email, name, contact_number) VALUES (?, ?, ?, ?)`, groupID, m.Email, m.Name, m.ContactNumber)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert group member: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return groupID, nil
+}
+
+// FulfillGroupOrder issues a ticket to every member of a verified group
+// order, creating user accounts for members who have not signed up yet.
+// It returns the ids of the tickets issued by this call.
+func FulfillGroupOrder(ctx context.Context, txnId string) ([]int, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	var (
+		groupID         int
+		ticketTitle     string
+		amount          float64
+		isAccommodation bool
+	)
+	query := `
+	SELECT g.id, g.ticket_title, t.amount, g.isAccommodation
+	FROM group_orders g
+	JOIN transactions t ON t.id = g.txn_id
+	WHERE g.txn_id = ? AND t.is_verified = TRUE
+	`
+	err := db.QueryRowContext(ctx, query, txnId).Scan(&groupID, &ticketTitle, &amount, &isAccommodation)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("group transaction not found or not verified")
+		}
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var total int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM group_order_members WHERE group_id = ?`, groupID).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count group members: %w", err)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("group order %d has no members", groupID)
+	}
+	price := amount / float64(total)
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, email, name, contact_number FROM group_order_members WHERE group_id = ? AND ticket_id IS NULL`, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group members: %w", err)
+	}
+	type pending struct {
+		id                         int
+		email, name, contactNumber string
+	}
+	var members []pending
+	for rows.Next() {
+		var m pending
+		if err := rows.Scan(&m.id, &m.email, &m.name, &m.contactNumber); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan group member: %w", err)
+		}
+		members = append(members, m)
+	}
+	rows.Close()
+
+	var ticketIDs []int
+	for _, m := range members {
+		userID, err := findOrCreateUser(ctx, tx, m.email, m.name, m.contactNumber)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := tx.ExecContext(ctx, `INSERT INTO purchased_tickets (user_id, ticket_title, price, isAccommodation) VALUES (?, ?, ?, ?)`, userID, ticketTitle, price, isAccommodation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add ticket: %w", err)
+		}
+		ticketID, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve last insert ID: %w", err)
+		}
+		if _, err := issuePassCode(ctx, tx, ticketID); err != nil {
+			return nil, err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE group_order_members SET ticket_id = ? WHERE id = ?`, ticketID, m.id); err != nil {
+			return nil, fmt.Errorf("failed to update group member: %w", err)
+		}
+		ticketIDs = append(ticketIDs, int(ticketID))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Group order %d fulfilled with %d tickets for transaction ID %s", groupID, len(ticketIDs), txnId)
+	return ticketIDs, nil
+}
+
+// findOrCreateUser returns the id of the user with the given email, creating
+// an account with the supplied details if there is none.
+func findOrCreateUser(ctx context.Context, tx *sql.Tx, email, name, contactNumber string) (int64, error) {
+	var id int64
+	err := tx.QueryRowContext(ctx, `SELECT id FROM users WHERE email = ?`, email).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO users (email, name, contact_number, data) VALUES (?, ?, ?, '""')`, email, name, contactNumber)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert user: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// GetGroupOrders returns the group orders paid by a user with their members.
+func GetGroupOrders(ctx context.Context, payerID int) ([]model.GroupOrder, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	SELECT g.id, g.txn_id, g.ticket_title, t.amount, g.isAccommodation, t.is_verified, g.created_at
+	FROM group_orders g
+	JOIN transactions t ON t.id = g.txn_id
+	WHERE g.payer_id = ?
+	ORDER BY g.id DESC
+	`
+	rows, err := db.QueryContext(ctx, query, payerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group orders: %w", err)
+	}
+
+	orders := []model.GroupOrder{}
+	for rows.Next() {
+		var o model.GroupOrder
+		if err := rows.Scan(&o.ID, &o.TxnID, &o.TicketTitle, &o.Amount, &o.IsAccommodation, &o.IsVerified, &o.CreatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan group order: %w", err)
+		}
+		orders = append(orders, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range orders {
+		members, err := getGroupMembers(ctx, orders[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		orders[i].Members = members
+	}
+
+	return orders, nil
+}
+
+func getGroupMembers(ctx context.Context, groupID int) ([]model.GroupMember, error) {
+	rows, err := db.QueryContext(ctx, `SELECT email, name, contact_number, ticket_id FROM group_order_members WHERE group_id = ? ORDER BY id`, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []model.GroupMember
+	for rows.Next() {
+		var (
+			m        model.GroupMember
+			ticketID sql.NullInt64
+		)
+		if err := rows.Scan(&m.Email, &m.Name, &m.ContactNumber, &ticketID); err != nil {
+			return nil, fmt.Errorf("failed to scan group member: %w", err)
+		}
+		if ticketID.Valid {
+			id := int(ticketID.Int64)
+			m.TicketID = &id
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
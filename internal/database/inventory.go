@@ -0,0 +1,472 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"reg/internal/model"
+	"strconv"
+	"time"
+)
+
+var ErrSoldOut = errors.New("tickets sold out")
+
+// sqliteTime matches the format of SQLite's datetime() so stored times
+// compare correctly against datetime('now').
+const sqliteTime = "2006-01-02 15:04:05"
+
+// holdDuration is how long a seat is reserved for a user who started a
+// payment but has not submitted a transaction id yet.
+func holdDuration() time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv("TICKET_HOLD_MINUTES")); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 30 * time.Minute
+}
+
+// promotionHoldDuration is how long a user promoted from the waitlist has
+// to complete their purchase.
+func promotionHoldDuration() time.Duration {
+	if hours, err := strconv.Atoi(os.Getenv("WAITLIST_HOLD_HOURS")); err == nil && hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// verificationWindow is how long seats stay reserved for a submitted
+// transaction id. Payments that are not verified by then give their seats
+// back, verifying one later still issues the ticket.
+func verificationWindow() time.Duration {
+	if hours, err := strconv.Atoi(os.Getenv("TXN_HOLD_HOURS")); err == nil && hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return 72 * time.Hour
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// availability returns the capacity of a tier along with the tickets sold
+// and the seats currently held. Tiers missing from the catalog are uncapped.
+func availability(ctx context.Context, q queryRower, title string) (capacity, sold, held int, err error) {
+	err = q.QueryRowContext(ctx, `SELECT capacity FROM tickets WHERE name = ?`, title).Scan(&capacity)
+	if errors.Is(err, sql.ErrNoRows) {
+		capacity, err = -1, nil
+	}
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to fetch capacity: %w", err)
+	}
+
+	err = q.QueryRowContext(ctx, `SELECT COUNT(*) FROM purchased_tickets WHERE ticket_title = ? AND status = 'active'`, title).Scan(&sold)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to count sold tickets: %w", err)
+	}
+
+	err = q.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(quantity), 0) FROM ticket_holds
+		WHERE ticket_title = ? AND status = 'active' AND (expires_at IS NULL OR expires_at > datetime('now'))
+	`, title).Scan(&held)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to count held tickets: %w", err)
+	}
+
+	return capacity, sold, held, nil
+}
+
+// ReserveTickets holds seats of a tier for a user. A hold without a
+// transaction id expires after a short while; once a transaction id is
+// attached the seats stay reserved until the payment is verified, for up to
+// the verification window. An
+// unattached hold the user already owns is reused. It returns ErrSoldOut
+// when the tier has no free seats left.
+func ReserveTickets(ctx context.Context, userID int, title string, quantity int, txnID string) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is not initialized")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		holdID       int64
+		heldQuantity int
+		expiresAt    sql.NullString
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, quantity, expires_at FROM ticket_holds
+		WHERE user_id = ? AND ticket_title = ? AND status = 'active' AND txn_id IS NULL
+			AND (expires_at IS NULL OR expires_at > datetime('now'))
+		ORDER BY id LIMIT 1
+	`, userID, title).Scan(&holdID, &heldQuantity, &expiresAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to fetch hold: %w", err)
+	}
+
+	capacity, sold, held, err := availability(ctx, tx, title)
+	if err != nil {
+		return 0, err
+	}
+	if capacity >= 0 && capacity-sold-held < quantity-heldQuantity {
+		return 0, ErrSoldOut
+	}
+
+	var expiry, txn any
+	if txnID != "" {
+		txn = txnID
+		expiry = time.Now().UTC().Add(verificationWindow()).Format(sqliteTime)
+	} else {
+		newExpiry := time.Now().UTC().Add(holdDuration()).Format(sqliteTime)
+		if expiresAt.Valid && expiresAt.String > newExpiry {
+			newExpiry = expiresAt.String
+		}
+		expiry = newExpiry
+	}
+
+	if holdID != 0 {
+		_, err = tx.ExecContext(ctx, `UPDATE ticket_holds SET quantity = ?, txn_id = ?, expires_at = ? WHERE id = ?`, quantity, txn, expiry, holdID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update hold: %w", err)
+		}
+	} else {
+		result, err := tx.ExecContext(ctx, `INSERT INTO ticket_holds (user_id, ticket_title, quantity, txn_id, expires_at) VALUES (?, ?, ?, ?, ?)`, userID, title, quantity, txn, expiry)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert hold: %w", err)
+		}
+		if holdID, err = result.LastInsertId(); err != nil {
+			return 0, fmt.Errorf("failed to retrieve last insert ID: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return holdID, nil
+}
+
+// DetachHold removes the transaction id from a hold, e.g. when recording
+// the transaction failed, so that it expires like any other hold.
+func DetachHold(ctx context.Context, holdID int64) error {
+	expiry := time.Now().UTC().Add(holdDuration()).Format(sqliteTime)
+	_, err := db.ExecContext(ctx, `UPDATE ticket_holds SET txn_id = NULL, expires_at = ? WHERE id = ? AND status = 'active'`, expiry, holdID)
+	if err != nil {
+		return fmt.Errorf("failed to detach hold: %w", err)
+	}
+	return nil
+}
+
+// ConvertHold marks a hold as used once its tickets have been issued.
+func ConvertHold(ctx context.Context, holdID int64) error {
+	return convertHolds(ctx, `id = ?`, holdID)
+}
+
+// ConvertTransactionHolds marks the holds of a verified transaction as used.
+func ConvertTransactionHolds(ctx context.Context, txnID string) error {
+	return convertHolds(ctx, `txn_id = ?`, txnID)
+}
+
+func convertHolds(ctx context.Context, where string, arg any) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE waitlist SET status = 'fulfilled'
+		WHERE status = 'promoted' AND (user_id, ticket_title) IN (
+			SELECT user_id, ticket_title FROM ticket_holds WHERE status = 'active' AND `+where+`
+		)
+	`, arg)
+	if err != nil {
+		return fmt.Errorf("failed to update waitlist: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx, `UPDATE ticket_holds SET status = 'converted' WHERE status = 'active' AND `+where, arg)
+	if err != nil {
+		return fmt.Errorf("failed to convert hold: %w", err)
+	}
+	return nil
+}
+
+// ExpireHolds releases holds whose time ran out and returns the tiers that
+// got seats back. Waitlist promotions whose hold expired are forfeited.
+// Holds of transactions that were never verified expire at the end of the
+// verification window.
+func ExpireHolds(ctx context.Context) ([]string, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	// Transaction holds used to be kept without an expiry, they get the
+	// verification window counted from when they were taken
+	window := fmt.Sprintf("+%d seconds", int(verificationWindow().Seconds()))
+	_, err := db.ExecContext(ctx, `UPDATE ticket_holds SET expires_at = datetime(created_at, ?) WHERE status = 'active' AND expires_at IS NULL AND txn_id IS NOT NULL`, window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set expiry of transaction holds: %w", err)
+	}
+
+	expired := `status = 'active' AND expires_at IS NOT NULL AND expires_at <= datetime('now')`
+
+	rows, err := db.QueryContext(ctx, `SELECT DISTINCT ticket_title FROM ticket_holds WHERE `+expired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired holds: %w", err)
+	}
+	var titles []string
+	for rows.Next() {
+		var title string
+		if err := rows.Scan(&title); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan expired hold: %w", err)
+		}
+		titles = append(titles, title)
+	}
+	rows.Close()
+	if len(titles) == 0 {
+		return nil, rows.Err()
+	}
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE waitlist SET status = 'expired'
+		WHERE status = 'promoted' AND (user_id, ticket_title) IN (
+			SELECT user_id, ticket_title FROM ticket_holds WHERE `+expired+`
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update waitlist: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, `UPDATE ticket_holds SET status = 'expired' WHERE `+expired); err != nil {
+		return nil, fmt.Errorf("failed to expire holds: %w", err)
+	}
+
+	return titles, nil
+}
+
+// CancelTicket cancels an active ticket and returns its tier.
+func CancelTicket(ctx context.Context, ticketID int) (string, error) {
+	if db == nil {
+		return "", fmt.Errorf("database connection is not initialized")
+	}
+
+	var title string
+	err := db.QueryRowContext(ctx, `SELECT ticket_title FROM purchased_tickets WHERE id = ? AND status = 'active'`, ticketID).Scan(&title)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrTicketNotFound
+		}
+		return "", fmt.Errorf("failed to fetch ticket: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, `UPDATE purchased_tickets SET status = 'cancelled' WHERE id = ?`, ticketID); err != nil {
+		return "", fmt.Errorf("failed to cancel ticket: %w", err)
+	}
+	return title, nil
+}
+
+// SetTicketCapacity caps the number of tickets sold for a tier, -1 removes
+// the cap.
+func SetTicketCapacity(ctx context.Context, title string, capacity int) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	result, err := db.ExecContext(ctx, `UPDATE tickets SET capacity = ? WHERE name = ?`, capacity, title)
+	if err != nil {
+		return fmt.Errorf("failed to update capacity: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTicketNotFound
+	}
+	return nil
+}
+
+// GetInventory returns the stock of every tier in the catalog.
+func GetInventory(ctx context.Context) ([]model.Inventory, error) {
+	catalog, err := GetCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	inventory := make([]model.Inventory, 0, len(catalog))
+	for _, t := range catalog {
+		capacity, sold, held, err := availability(ctx, db, t.Title)
+		if err != nil {
+			return nil, err
+		}
+
+		inv := model.Inventory{Title: t.Title, Capacity: capacity, Sold: sold, Held: held, Available: -1}
+		if capacity >= 0 {
+			inv.Available = max(capacity-sold-held, 0)
+		}
+		err = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM waitlist WHERE ticket_title = ? AND status = 'waiting'`, t.Title).Scan(&inv.Waiting)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count waitlist: %w", err)
+		}
+		inventory = append(inventory, inv)
+	}
+	return inventory, nil
+}
+
+// JoinWaitlist adds the user to the waitlist of a tier and returns their
+// position. Joining twice keeps the original place.
+func JoinWaitlist(ctx context.Context, userID int, title string) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is not initialized")
+	}
+
+	var id int
+	err := db.QueryRowContext(ctx, `SELECT id FROM waitlist WHERE user_id = ? AND ticket_title = ? AND status = 'waiting'`, userID, title).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		result, err := db.ExecContext(ctx, `INSERT INTO waitlist (user_id, ticket_title) VALUES (?, ?)`, userID, title)
+		if err != nil {
+			return 0, fmt.Errorf("failed to join waitlist: %w", err)
+		}
+		lastID, err := result.LastInsertId()
+		if err != nil {
+			return 0, fmt.Errorf("failed to retrieve last insert ID: %w", err)
+		}
+		id = int(lastID)
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to fetch waitlist entry: %w", err)
+	}
+
+	return waitlistPosition(ctx, id)
+}
+
+func waitlistPosition(ctx context.Context, id int) (int, error) {
+	var position int
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM waitlist w
+		JOIN waitlist me ON me.id = ?
+		WHERE w.ticket_title = me.ticket_title AND w.status = 'waiting' AND w.id <= me.id
+	`, id).Scan(&position)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute waitlist position: %w", err)
+	}
+	return position, nil
+}
+
+// LeaveWaitlist removes the user from the waitlist of a tier.
+func LeaveWaitlist(ctx context.Context, userID int, title string) error {
+	_, err := db.ExecContext(ctx, `UPDATE waitlist SET status = 'left' WHERE user_id = ? AND ticket_title = ? AND status = 'waiting'`, userID, title)
+	if err != nil {
+		return fmt.Errorf("failed to leave waitlist: %w", err)
+	}
+	return nil
+}
+
+// GetWaitlist lists waitlist entries, optionally limited to one user and/or
+// tier (zero values match everything), oldest first.
+func GetWaitlist(ctx context.Context, userID int, title string) ([]model.WaitlistEntry, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	SELECT w.id, w.user_id, u.name, u.email, w.ticket_title, w.status, w.created_at,
+		COALESCE((
+			SELECT h.expires_at FROM ticket_holds h
+			WHERE h.user_id = w.user_id AND h.ticket_title = w.ticket_title AND h.status = 'active'
+			ORDER BY h.id DESC LIMIT 1
+		), '')
+	FROM waitlist w
+	JOIN users u ON u.id = w.user_id
+	WHERE (? = 0 OR w.user_id = ?) AND (? = '' OR w.ticket_title = ?)
+	ORDER BY w.id
+	`
+	rows, err := db.QueryContext(ctx, query, userID, userID, title, title)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query waitlist: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []model.WaitlistEntry{}
+	positions := make(map[string]int)
+	for rows.Next() {
+		var e model.WaitlistEntry
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Name, &e.Email, &e.TicketTitle, &e.Status, &e.CreatedAt, &e.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan waitlist entry: %w", err)
+		}
+		if e.Status != "promoted" {
+			e.ExpiresAt = ""
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		if entries[i].Status != "waiting" {
+			continue
+		}
+		if userID == 0 {
+			positions[entries[i].TicketTitle]++
+			entries[i].Position = positions[entries[i].TicketTitle]
+			continue
+		}
+		position, err := waitlistPosition(ctx, entries[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		entries[i].Position = position
+	}
+	return entries, nil
+}
+
+// PromoteWaitlist gives free seats of a tier to the longest waiting users by
+// reserving a seat for each of them, and returns the promoted entries.
+func PromoteWaitlist(ctx context.Context, title string) ([]model.WaitlistEntry, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	capacity, sold, held, err := availability(ctx, tx, title)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry := time.Now().UTC().Add(promotionHoldDuration()).Format(sqliteTime)
+	var promoted []model.WaitlistEntry
+	for capacity < 0 || capacity-sold-held > 0 {
+		var e model.WaitlistEntry
+		err := tx.QueryRowContext(ctx, `
+			SELECT w.id, w.user_id, u.name, u.email, w.ticket_title, w.created_at
+			FROM waitlist w
+			JOIN users u ON u.id = w.user_id
+			WHERE w.ticket_title = ? AND w.status = 'waiting'
+			ORDER BY w.id LIMIT 1
+		`, title).Scan(&e.ID, &e.UserID, &e.Name, &e.Email, &e.TicketTitle, &e.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch waitlist entry: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO ticket_holds (user_id, ticket_title, quantity, expires_at) VALUES (?, ?, 1, ?)`, e.UserID, title, expiry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert hold: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE waitlist SET status = 'promoted', promoted_at = CURRENT_TIMESTAMP WHERE id = ?`, e.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to promote waitlist entry: %w", err)
+		}
+
+		e.Status = "promoted"
+		e.ExpiresAt = expiry
+		promoted = append(promoted, e)
+		held++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return promoted, nil
+}
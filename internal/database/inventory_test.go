@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// openInventoryDB opens a test database where PREMIUM has capacity seats and
+// returns two users who want one.
+func openInventoryDB(t *testing.T, capacity int) (first, second int) {
+	t.Helper()
+	openTestDB(t)
+	if err := SetTicketCapacity(context.Background(), "PREMIUM", capacity); err != nil {
+		t.Fatal(err)
+	}
+	return createTestUser(t, "first@example.com"), createTestUser(t, "second@example.com")
+}
+
+func expireTestHolds(t *testing.T, userID int) {
+	t.Helper()
+	_, err := db.Exec(`UPDATE ticket_holds SET expires_at = '2000-01-01 00:00:00' WHERE user_id = ? AND expires_at IS NOT NULL`, userID)
+	if err != nil {
+		t.Fatalf("failed to expire holds: %v", err)
+	}
+}
+
+func TestReserveTicketsFreeSeat(t *testing.T) {
+	ctx := context.Background()
+	first, second := openInventoryDB(t, 2)
+
+	if _, err := ReserveTickets(ctx, first, "PREMIUM", 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReserveTickets(ctx, second, "PREMIUM", 1, ""); err != nil {
+		t.Errorf("second seat: %v", err)
+	}
+}
+
+func TestReserveTicketsDoesNotOversell(t *testing.T) {
+	ctx := context.Background()
+	first, second := openInventoryDB(t, 1)
+
+	if _, err := ReserveTickets(ctx, first, "PREMIUM", 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReserveTickets(ctx, second, "PREMIUM", 1, ""); !errors.Is(err, ErrSoldOut) {
+		t.Errorf("got error %v, want ErrSoldOut", err)
+	}
+}
+
+func TestReserveTicketsReusesOwnHold(t *testing.T) {
+	ctx := context.Background()
+	first, second := openInventoryDB(t, 1)
+
+	holdID, err := ReserveTickets(ctx, first, "PREMIUM", 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ReserveTickets(ctx, first, "PREMIUM", 1, "")
+	if err != nil {
+		t.Fatalf("reserving again: %v", err)
+	}
+	if again != holdID {
+		t.Errorf("got hold %d, want %d", again, holdID)
+	}
+	if _, err := ReserveTickets(ctx, second, "PREMIUM", 1, ""); !errors.Is(err, ErrSoldOut) {
+		t.Errorf("got error %v, want ErrSoldOut", err)
+	}
+}
+
+func TestExpireHoldsFreesSeat(t *testing.T) {
+	ctx := context.Background()
+	first, second := openInventoryDB(t, 1)
+
+	if _, err := ReserveTickets(ctx, first, "PREMIUM", 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	expireTestHolds(t, first)
+	titles, err := ExpireHolds(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(titles, []string{"PREMIUM"}) {
+		t.Errorf("got expired tiers %v, want [PREMIUM]", titles)
+	}
+	if _, err := ReserveTickets(ctx, second, "PREMIUM", 1, ""); err != nil {
+		t.Errorf("seat of the expired hold: %v", err)
+	}
+}
+
+func TestExpireHoldsKeepsTransactionHoldsForVerification(t *testing.T) {
+	ctx := context.Background()
+	first, second := openInventoryDB(t, 1)
+
+	if _, err := ReserveTickets(ctx, first, "PREMIUM", 1, "TXN1"); err != nil {
+		t.Fatal(err)
+	}
+	titles, err := ExpireHolds(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(titles) != 0 {
+		t.Errorf("got expired tiers %v, want none", titles)
+	}
+	if _, err := ReserveTickets(ctx, second, "PREMIUM", 1, ""); !errors.Is(err, ErrSoldOut) {
+		t.Errorf("got error %v, want ErrSoldOut", err)
+	}
+
+	// A transaction that is never verified gives the seat back
+	expireTestHolds(t, first)
+	if titles, err = ExpireHolds(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(titles, []string{"PREMIUM"}) {
+		t.Errorf("got expired tiers %v, want [PREMIUM]", titles)
+	}
+	if _, err := ReserveTickets(ctx, second, "PREMIUM", 1, ""); err != nil {
+		t.Errorf("seat of the unverified transaction: %v", err)
+	}
+}
+
+func TestExpireHoldsDatesTransactionHoldsWithoutExpiry(t *testing.T) {
+	ctx := context.Background()
+	first, _ := openInventoryDB(t, 1)
+
+	_, err := db.Exec(`INSERT INTO ticket_holds (user_id, ticket_title, txn_id, created_at) VALUES (?, 'PREMIUM', 'OLD', '2000-01-01 00:00:00'), (?, 'PREMIUM', 'NEW', datetime('now'))`, first, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExpireHolds(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var old, recent string
+	db.QueryRow(`SELECT status FROM ticket_holds WHERE txn_id = 'OLD'`).Scan(&old)
+	db.QueryRow(`SELECT status FROM ticket_holds WHERE txn_id = 'NEW'`).Scan(&recent)
+	if old != "expired" || recent != "active" {
+		t.Errorf("got statuses %s and %s, want the old hold expired and the new one active", old, recent)
+	}
+}
+
+func TestExpireHoldsReleasesDetachedHolds(t *testing.T) {
+	ctx := context.Background()
+	first, second := openInventoryDB(t, 1)
+
+	holdID, err := ReserveTickets(ctx, first, "PREMIUM", 1, "TXN1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DetachHold(ctx, holdID); err != nil {
+		t.Fatal(err)
+	}
+	expireTestHolds(t, first)
+	if _, err := ExpireHolds(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReserveTickets(ctx, second, "PREMIUM", 1, ""); err != nil {
+		t.Errorf("seat of the detached hold: %v", err)
+	}
+}
+
+func TestReserveTicketsCountsSoldTickets(t *testing.T) {
+	ctx := context.Background()
+	first, second := openInventoryDB(t, 1)
+
+	if err := AddBasicTickets(first, "PREMIUM"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReserveTickets(ctx, second, "PREMIUM", 1, ""); !errors.Is(err, ErrSoldOut) {
+		t.Errorf("got error %v, want ErrSoldOut", err)
+	}
+}
+
+func TestConvertHoldIsNotCountedTwice(t *testing.T) {
+	ctx := context.Background()
+	first, second := openInventoryDB(t, 2)
+
+	holdID, err := ReserveTickets(ctx, first, "PREMIUM", 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddBasicTickets(first, "PREMIUM"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ConvertHold(ctx, holdID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReserveTickets(ctx, second, "PREMIUM", 1, ""); err != nil {
+		t.Errorf("second seat: %v", err)
+	}
+}
@@ -0,0 +1,292 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reg/internal/model"
+	"strings"
+	"time"
+)
+
+// Outbox statuses
+const (
+	OutboxPending = "pending"
+	OutboxSending = "sending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead"
+)
+
+var (
+	ErrOutboxEmailNotFound = errors.New("email not found")
+	ErrOutboxEmailNotDead  = errors.New("email is not dead")
+	ErrOutboxEmailPending  = errors.New("email has not been delivered yet")
+)
+
+const outboxColumns = `id, sender, recipients, to_addr, subject, message, ticket_id, user_id, template, resent_from, status, attempts, COALESCE(last_error, ''), next_attempt_at, created_at, sent_at`
+
+func scanOutboxEmail(row rowScanner, withMessage bool) (*model.OutboxEmail, error) {
+	var (
+		e          model.OutboxEmail
+		recipients string
+		message    []byte
+		ticketID   sql.NullInt64
+		userID     sql.NullInt64
+		resentFrom sql.NullInt64
+		next, sent sql.NullString
+	)
+	if err := row.Scan(&e.ID, &e.From, &recipients, &e.To, &e.Subject, &message, &ticketID, &userID, &e.Template, &resentFrom, &e.Status, &e.Attempts, &e.LastError, &next, &e.CreatedAt, &sent); err != nil {
+		return nil, err
+	}
+	e.Recipients = strings.Split(recipients, ",")
+	if withMessage {
+		e.Message = message
+	}
+	if ticketID.Valid {
+		id := int(ticketID.Int64)
+		e.TicketID = &id
+	}
+	if userID.Valid {
+		id := int(userID.Int64)
+		e.UserID = &id
+	}
+	if resentFrom.Valid {
+		id := int(resentFrom.Int64)
+		e.ResentFrom = &id
+	}
+	if next.Valid {
+		e.NextAttemptAt = &next.String
+	}
+	if sent.Valid {
+		e.SentAt = &sent.String
+	}
+	return &e, nil
+}
+
+// EnqueueEmail stores a built message for the outbox workers to deliver.
+// TicketID links a pass email to its ticket and is nil for other mail. The
+// email is linked to the user it is addressed to, when there is one.
+func EnqueueEmail(ctx context.Context, e model.OutboxEmail) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is not initialized")
+	}
+	return enqueueEmail(ctx, db, e)
+}
+
+func enqueueEmail(ctx context.Context, ex execer, e model.OutboxEmail) (int64, error) {
+	query := `
+	INSERT INTO email_outbox (sender, recipients, to_addr, subject, message, ticket_id, user_id, template, resent_from)
+	VALUES (?, ?, ?, ?, ?, ?, (SELECT id FROM users WHERE LOWER(email) = LOWER(?)), ?, ?)`
+	result, err := ex.ExecContext(ctx, query, e.From, strings.Join(e.Recipients, ","), e.To, e.Subject, e.Message, e.TicketID, e.To, e.Template, e.ResentFrom)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue email: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// ClaimDueEmail marks the oldest email due for delivery as sending and
+// returns it, nil when nothing is due. Each claim counts as an attempt.
+func ClaimDueEmail(ctx context.Context) (*model.OutboxEmail, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	UPDATE email_outbox
+	SET status = 'sending', attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP
+	WHERE id = (
+		SELECT id FROM email_outbox
+		WHERE status = 'pending' AND next_attempt_at <= ?
+		ORDER BY next_attempt_at, id
+		LIMIT 1
+	)
+	RETURNING ` + outboxColumns
+	e, err := scanOutboxEmail(db.QueryRowContext(ctx, query, time.Now().UTC().Format(sqliteTime)), true)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim email: %w", err)
+	}
+	return e, nil
+}
+
+// MarkEmailSent records that an email was accepted by the mail server.
+func MarkEmailSent(ctx context.Context, id int) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `UPDATE email_outbox SET status = 'sent', last_error = '', next_attempt_at = NULL, sent_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	if _, err := db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to mark email sent: %w", err)
+	}
+	return nil
+}
+
+// MarkEmailFailed records a failed delivery. The email is tried again at
+// retryAt, or moved to the dead letters when retryAt is nil.
+func MarkEmailFailed(ctx context.Context, id int, sendErr error, retryAt *time.Time) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	status, next := OutboxDead, sql.NullString{}
+	if retryAt != nil {
+		status, next = OutboxPending, sql.NullString{String: retryAt.UTC().Format(sqliteTime), Valid: true}
+	}
+	query := `UPDATE email_outbox SET status = ?, last_error = ?, next_attempt_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	if _, err := db.ExecContext(ctx, query, status, sendErr.Error(), next, id); err != nil {
+		return fmt.Errorf("failed to mark email failed: %w", err)
+	}
+	return nil
+}
+
+// RecoverOutbox puts back emails a previous process was sending when it
+// stopped, so they are delivered again.
+func RecoverOutbox(ctx context.Context) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is not initialized")
+	}
+
+	result, err := db.ExecContext(ctx, `UPDATE email_outbox SET status = 'pending', updated_at = CURRENT_TIMESTAMP WHERE status = 'sending'`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to recover outbox: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetOutboxEmails lists the latest emails matching a filter. Recipient
+// matches part of any address the email went to. Messages are left out.
+func GetOutboxEmails(ctx context.Context, f model.OutboxFilter) ([]model.OutboxEmail, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	SELECT ` + outboxColumns + ` FROM email_outbox
+	WHERE (? = '' OR INSTR(LOWER(recipients), LOWER(?)) > 0)
+		AND (? = '' OR template = ?)
+		AND (? = '' OR status = ?)
+		AND (? = 0 OR user_id = ?)
+		AND (? = '' OR created_at >= ?)
+		AND (? = '' OR created_at < ?)
+	ORDER BY id DESC
+	LIMIT ? OFFSET ?`
+	rows, err := db.QueryContext(ctx, query,
+		f.Recipient, f.Recipient, f.Template, f.Template, f.Status, f.Status, f.UserID, f.UserID,
+		f.Since, f.Since, f.Until, f.Until, f.Limit, f.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox: %w", err)
+	}
+	defer rows.Close()
+
+	emails := []model.OutboxEmail{}
+	for rows.Next() {
+		e, err := scanOutboxEmail(rows, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox email: %w", err)
+		}
+		emails = append(emails, *e)
+	}
+	return emails, rows.Err()
+}
+
+// GetOutboxEmail returns an email with its message.
+func GetOutboxEmail(ctx context.Context, id int) (*model.OutboxEmail, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	e, err := scanOutboxEmail(db.QueryRowContext(ctx, `SELECT `+outboxColumns+` FROM email_outbox WHERE id = ?`, id), true)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOutboxEmailNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch outbox email: %w", err)
+	}
+	return e, nil
+}
+
+// RetryOutboxEmail gives a dead email a fresh set of attempts, starting now.
+// A pass email puts its ticket back in the queued state.
+func RetryOutboxEmail(ctx context.Context, id int) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE pass_dispatch SET status = 'queued', updated_at = CURRENT_TIMESTAMP WHERE ticket_id = (SELECT ticket_id FROM email_outbox WHERE id = ? AND status = 'dead')`, id); err != nil {
+		return fmt.Errorf("failed to requeue pass: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, `UPDATE email_outbox SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = 'dead'`, id)
+	if err != nil {
+		return fmt.Errorf("failed to retry email: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM email_outbox WHERE id = ?)`, id).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to fetch outbox email: %w", err)
+		}
+		if !exists {
+			return ErrOutboxEmailNotFound
+		}
+		return ErrOutboxEmailNotDead
+	}
+	return tx.Commit()
+}
+
+// RetryDeadEmails gives every dead email a fresh set of attempts.
+func RetryDeadEmails(ctx context.Context) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is not initialized")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE pass_dispatch SET status = 'queued', updated_at = CURRENT_TIMESTAMP WHERE ticket_id IN (SELECT ticket_id FROM email_outbox WHERE status = 'dead')`); err != nil {
+		return 0, fmt.Errorf("failed to requeue passes: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, `UPDATE email_outbox SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE status = 'dead'`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retry emails: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n, tx.Commit()
+}
+
+// GetOutboxSummary counts emails by status.
+func GetOutboxSummary(ctx context.Context) (map[string]int, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT status, COUNT(*) FROM email_outbox GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox summary: %w", err)
+	}
+	defer rows.Close()
+
+	summary := map[string]int{OutboxPending: 0, OutboxSending: 0, OutboxSent: 0, OutboxDead: 0}
+	for rows.Next() {
+		var (
+			status string
+			n      int
+		)
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox summary: %w", err)
+		}
+		summary[status] = n
+	}
+	return summary, rows.Err()
+}
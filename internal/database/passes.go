@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reg/internal/model"
+	"reg/internal/passes"
+)
+
+var ErrInvalidPass = errors.New("invalid pass")
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// issuePassCode signs a new pass code for a ticket and stores it, replacing
+// any code the ticket had before.
+func issuePassCode(ctx context.Context, ex execer, ticketID int64) (string, error) {
+	code, err := passes.NewCode(int(ticketID))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate pass code: %w", err)
+	}
+	if _, err := ex.ExecContext(ctx, `UPDATE purchased_tickets SET pass_code = ? WHERE id = ?`, code, ticketID); err != nil {
+		return "", fmt.Errorf("failed to store pass code: %w", err)
+	}
+	return code, nil
+}
+
+// BackfillPassCodes issues pass codes for tickets created before they
+// existed and returns their ids. Passes already mailed to these tickets
+// carry a barcode that no longer scans, so each ticket is flagged as
+// backfilled and its dispatch record is cleared for the next dispatch job
+// to mail the new code.
+func BackfillPassCodes(ctx context.Context) ([]int64, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT id FROM purchased_tickets WHERE pass_code IS NULL OR pass_code = ''`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tickets: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan ticket: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if err := backfillPassCode(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+func backfillPassCode(ctx context.Context, ticketID int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := issuePassCode(ctx, tx, ticketID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE purchased_tickets SET pass_code_backfilled = TRUE WHERE id = ?`, ticketID); err != nil {
+		return fmt.Errorf("failed to flag ticket %d as backfilled: %w", ticketID, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM pass_dispatch WHERE ticket_id = ?`, ticketID); err != nil {
+		return fmt.Errorf("failed to reset dispatch of ticket %d: %w", ticketID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// VerifyPassCode checks the signature of a pass code and that it is the code
+// currently stored on its ticket. Forged, malformed and replaced codes all
+// return ErrInvalidPass.
+func VerifyPassCode(ctx context.Context, code string) (*model.UserTicket, error) {
+	ticketID, err := passes.Verify(code)
+	if err != nil {
+		if errors.Is(err, passes.ErrInvalidCode) {
+			return nil, ErrInvalidPass
+		}
+		return nil, err
+	}
+
+	ticket, err := GetUserTicket(ctx, ticketID)
+	if err != nil {
+		if errors.Is(err, ErrTicketNotFound) {
+			return nil, ErrInvalidPass
+		}
+		return nil, err
+	}
+	if ticket.UID != code {
+		return nil, ErrInvalidPass
+	}
+	return ticket, nil
+}
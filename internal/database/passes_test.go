@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBackfillPassCodesResetsDispatch(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	userID := createTestUser(t, "legacy@example.com")
+	result, err := db.Exec(`INSERT INTO purchased_tickets (user_id, ticket_title, price, isAccommodation) VALUES (?, 'STANDARD', 499, FALSE)`, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticketID, _ := result.LastInsertId()
+	if _, err := db.Exec(`INSERT INTO pass_dispatch (ticket_id, status, attempts) VALUES (?, 'sent', 1)`, ticketID); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := BackfillPassCodes(ctx)
+	if err != nil {
+		t.Fatalf("BackfillPassCodes: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != ticketID {
+		t.Fatalf("backfilled %v, want [%d]", ids, ticketID)
+	}
+
+	var (
+		code       string
+		backfilled bool
+		dispatched bool
+	)
+	db.QueryRow(`SELECT pass_code, pass_code_backfilled FROM purchased_tickets WHERE id = ?`, ticketID).Scan(&code, &backfilled)
+	db.QueryRow(`SELECT EXISTS(SELECT 1 FROM pass_dispatch WHERE ticket_id = ?)`, ticketID).Scan(&dispatched)
+	if code == "" || !backfilled {
+		t.Errorf("pass_code = %q, backfilled = %v, want a code and the flag", code, backfilled)
+	}
+	if dispatched {
+		t.Error("dispatch record kept, the new code would never be mailed")
+	}
+
+	if ids, err := BackfillPassCodes(ctx); err != nil || len(ids) != 0 {
+		t.Errorf("second BackfillPassCodes = %v, %v, want nothing to do", ids, err)
+	}
+}
+
+func TestMarkPassesSentSkipsBackfilledTickets(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	legacyID := createTestUser(t, "legacy@example.com")
+	if _, err := db.Exec(`INSERT INTO purchased_tickets (user_id, ticket_title, price, isAccommodation) VALUES (?, 'STANDARD', 499, FALSE)`, legacyID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := BackfillPassCodes(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddBasicTickets(createTestUser(t, "mailed@example.com"), "STANDARD"); err != nil {
+		t.Fatal(err)
+	}
+
+	marked, err := MarkPassesSent(ctx, []string{"legacy@example.com", "Mailed@example.com"})
+	if err != nil {
+		t.Fatalf("MarkPassesSent: %v", err)
+	}
+	if marked != 1 {
+		t.Errorf("marked %d passes, want only the one whose code was mailed", marked)
+	}
+
+	var dispatched bool
+	db.QueryRow(`SELECT EXISTS(SELECT 1 FROM pass_dispatch pd JOIN purchased_tickets pt ON pt.id = pd.ticket_id WHERE pt.user_id = ?)`, legacyID).Scan(&dispatched)
+	if dispatched {
+		t.Error("backfilled ticket was marked as sent")
+	}
+}
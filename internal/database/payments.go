@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -15,7 +16,7 @@ func InitiatePayment(amount float64, userId int) (int64, error) {
 	return result.LastInsertId()
 }
 
-func CreatePaymentRecord(txnId string, userID int, amount float64, ticketTitle string, isAccommodation bool, coupon string) (int64, error) {
+func CreatePaymentRecord(txnId string, userID int, amount float64, ticketTitle string, isAccommodation bool, coupon string, pricingPhase string) (int64, error) {
 	// First, check if a record with the same txnId already exists
 	var exists int
 	err := db.QueryRow(`SELECT 1 FROM transactions WHERE id = ?`, txnId).Scan(&exists)
@@ -27,7 +28,7 @@ func CreatePaymentRecord(txnId string, userID int, amount float64, ticketTitle s
 		return -1, nil
 	}
 
-	result, err := db.Exec(`INSERT INTO transactions (id, user_id, amount, ticket_title, isAccommodation, coupon) VALUES (?, ?, ?, ?, ?, ?)`, txnId, userID, amount, ticketTitle, isAccommodation, coupon)
+	result, err := db.Exec(`INSERT INTO transactions (id, user_id, amount, ticket_title, isAccommodation, coupon, pricing_phase) VALUES (?, ?, ?, ?, ?, ?, ?)`, txnId, userID, amount, ticketTitle, isAccommodation, coupon, pricingPhase)
 	if err != nil {
 		return 0, err
 	}
@@ -123,9 +124,8 @@ func AddTickets(userID int, txnID string) (string, error) {
 		INSERT INTO purchased_tickets (user_id, ticket_title, price, isAccommodation, coupon)
 		VALUES (?, ?, ?, ?, ?)
 	`
-	_, err = db.Exec(insertQuery, userID, ticketTitle, price, isAccommodation, coupon)
-	if err != nil {
-		return ticketTitle, fmt.Errorf("failed to add ticket: %v", err)
+	if err := insertTicket(context.Background(), insertQuery, userID, ticketTitle, price, isAccommodation, coupon); err != nil {
+		return ticketTitle, err
 	}
 
 	log.Printf("Ticket successfully added for user %d with transaction ID %s", userID, txnID)
@@ -137,11 +137,131 @@ func AddBasicTickets(userID int, ticketTitle string) error {
 		INSERT INTO purchased_tickets (user_id, ticket_title, price, isAccommodation)
 		VALUES (?, ?, ?, ?)
 	`
-	_, err := db.Exec(insertQuery, userID, ticketTitle, -1, false)
+	if err := insertTicket(context.Background(), insertQuery, userID, ticketTitle, -1, false); err != nil {
+		return err
+	}
+
+	log.Printf("Ticket successfully added for user %d", userID)
+	return nil
+}
+
+// insertTicket runs a purchased_tickets insert and issues the pass code of
+// the new ticket in the same transaction, so no ticket is left without one.
+func insertTicket(ctx context.Context, query string, args ...any) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to add ticket: %v", err)
 	}
 
-	log.Printf("Ticket successfully added for user %d", userID)
+	ticketID, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve last insert ID: %v", err)
+	}
+	if _, err := issuePassCode(ctx, tx, ticketID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
+
+// CreateUpgradeRecord stores a pending transaction that moves an existing
+// ticket to a higher tier once it is verified.
+func CreateUpgradeRecord(txnId string, userID int, ticketID int, amount float64, ticketTitle string, pricingPhase string) (int64, error) {
+	var exists int
+	err := db.QueryRow(`SELECT 1 FROM transactions WHERE id = ?`, txnId).Scan(&exists)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	if exists == 1 {
+		return -1, nil
+	}
+
+	result, err := db.Exec(`INSERT INTO transactions (id, user_id, amount, ticket_title, type, ticket_id, pricing_phase) VALUES (?, ?, ?, ?, 'upgrade', ?, ?)`, txnId, userID, amount, ticketTitle, ticketID, pricingPhase)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// HasPendingUpgrade reports whether an unverified upgrade exists for the ticket.
+func HasPendingUpgrade(ticketID int) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM transactions WHERE type = 'upgrade' AND ticket_id = ? AND is_verified = FALSE)`, ticketID).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// GetTransactionType returns the kind of a transaction, "purchase" for plain
+// ticket purchases.
+func GetTransactionType(txnID string) (string, error) {
+	var txnType sql.NullString
+	err := db.QueryRow(`SELECT type FROM transactions WHERE id = ?`, txnID).Scan(&txnType)
+	if err != nil {
+		return "", err
+	}
+	if !txnType.Valid || txnType.String == "" {
+		return "purchase", nil
+	}
+	return txnType.String, nil
+}
+
+// ApplyUpgrade replaces the tier on the ticket referenced by a verified
+// upgrade transaction and returns the upgraded ticket id and its previous tier.
+func ApplyUpgrade(userID int, txnID string) (int, string, error) {
+	var (
+		ticketID      int
+		ticketTitle   string
+		previousTitle string
+		amount        float64
+	)
+
+	query := `
+		SELECT ticket_id, ticket_title, amount
+		FROM transactions
+		WHERE id = ? AND user_id = ? AND type = 'upgrade' AND is_verified = TRUE
+	`
+	err := db.QueryRow(query, txnID, userID).Scan(&ticketID, &ticketTitle, &amount)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, "", fmt.Errorf("upgrade transaction not found or not verified")
+		}
+		return 0, "", err
+	}
+
+	err = db.QueryRow(`SELECT ticket_title FROM purchased_tickets WHERE id = ? AND user_id = ?`, ticketID, userID).Scan(&previousTitle)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, "", fmt.Errorf("ticket %d not found for user %d", ticketID, userID)
+		}
+		return 0, "", err
+	}
+
+	updateQuery := `
+		UPDATE purchased_tickets
+		SET ticket_title = ?,
+			price = MAX(price, 0) + ?
+		WHERE id = ? AND user_id = ?
+	`
+	result, err := db.Exec(updateQuery, ticketTitle, amount, ticketID, userID)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to upgrade ticket: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return 0, "", fmt.Errorf("ticket %d not found for user %d", ticketID, userID)
+	}
+
+	log.Printf("Ticket %d upgraded to %s for user %d with transaction ID %s", ticketID, ticketTitle, userID, txnID)
+	return ticketID, previousTitle, nil
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reg/internal/model"
+)
+
+// Email categories. Transactional emails (OTPs, receipts, passes) are always
+// sent; users can opt out of the others.
+const (
+	EmailTransactional = "transactional"
+	EmailAnnouncements = "announcements"
+	EmailReminders     = "reminders"
+)
+
+// preferenceColumns are the users columns holding the optional categories.
+var preferenceColumns = map[string]string{
+	EmailAnnouncements: "email_announcements",
+	EmailReminders:     "email_reminders",
+}
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrEmailCategory = errors.New("email category cannot be unsubscribed from")
+)
+
+// OptionalEmailCategory reports whether users can opt out of category.
+func OptionalEmailCategory(category string) bool {
+	_, ok := preferenceColumns[category]
+	return ok
+}
+
+// optedIn is the condition on users u wanting emails of category.
+func optedIn(category string) string {
+	column, ok := preferenceColumns[category]
+	if !ok {
+		return "1 = 1"
+	}
+	return "u." + column + " = TRUE"
+}
+
+// GetEmailPreferences returns the email categories a user receives.
+func GetEmailPreferences(ctx context.Context, userID int) (*model.EmailPreferences, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	p := model.EmailPreferences{Transactional: true}
+	err := db.QueryRowContext(ctx, `SELECT email_announcements, email_reminders FROM users WHERE id = ?`, userID).Scan(&p.Announcements, &p.Reminders)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch email preferences: %w", err)
+	}
+	return &p, nil
+}
+
+// SetEmailPreferences changes the categories given and returns the result.
+func SetEmailPreferences(ctx context.Context, userID int, announcements, reminders *bool) (*model.EmailPreferences, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	UPDATE users SET
+		email_announcements = COALESCE(?, email_announcements),
+		email_reminders = COALESCE(?, email_reminders)
+	WHERE id = ?`
+	result, err := db.ExecContext(ctx, query, announcements, reminders, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update email preferences: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrUserNotFound
+	}
+	return GetEmailPreferences(ctx, userID)
+}
+
+// Unsubscribe turns off one category for a user and returns their email.
+func Unsubscribe(ctx context.Context, userID int, category string) (string, error) {
+	if db == nil {
+		return "", fmt.Errorf("database connection is not initialized")
+	}
+	column, ok := preferenceColumns[category]
+	if !ok {
+		return "", ErrEmailCategory
+	}
+
+	var email string
+	err := db.QueryRowContext(ctx, `UPDATE users SET `+column+` = FALSE WHERE id = ? RETURNING email`, userID).Scan(&email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrUserNotFound
+		}
+		return "", fmt.Errorf("failed to unsubscribe: %w", err)
+	}
+	return email, nil
+}
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reg/internal/model"
+	"time"
+)
+
+var ErrPhaseNotFound = errors.New("pricing phase not found")
+
+// phaseSoldQuery counts the tickets sold in a pricing phase, group orders
+// count once per member.
+const phaseSoldQuery = `
+	SELECT COALESCE(SUM(
+		CASE WHEN t.type = 'group' THEN (
+			SELECT COUNT(*) FROM group_order_members m
+			JOIN group_orders g ON g.id = m.group_id
+			WHERE g.txn_id = t.id
+		) ELSE 1 END
+	), 0)
+	FROM transactions t
+	WHERE t.ticket_title = ? AND t.pricing_phase = ?
+`
+
+// GetPricingPhases lists the pricing phases of a tier, or of every tier
+// when title is empty.
+func GetPricingPhases(ctx context.Context, title string) ([]model.PricingPhase, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	SELECT id, ticket_title, name, price, starts_at, ends_at, max_quantity
+	FROM pricing_phases
+	WHERE ? = '' OR ticket_title = ?
+	ORDER BY ticket_title, COALESCE(starts_at, ''), price
+	`
+	rows, err := db.QueryContext(ctx, query, title, title)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pricing phases: %w", err)
+	}
+
+	phases := []model.PricingPhase{}
+	for rows.Next() {
+		var (
+			p              model.PricingPhase
+			startsAt, ends sql.NullString
+			maxQuantity    sql.NullInt64
+		)
+		if err := rows.Scan(&p.ID, &p.TicketTitle, &p.Name, &p.Price, &startsAt, &ends, &maxQuantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan pricing phase: %w", err)
+		}
+		if startsAt.Valid {
+			p.StartsAt = &startsAt.String
+		}
+		if ends.Valid {
+			p.EndsAt = &ends.String
+		}
+		if maxQuantity.Valid {
+			q := int(maxQuantity.Int64)
+			p.MaxQuantity = &q
+		}
+		phases = append(phases, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range phases {
+		if err := db.QueryRowContext(ctx, phaseSoldQuery, phases[i].TicketTitle, phases[i].Name).Scan(&phases[i].Sold); err != nil {
+			return nil, fmt.Errorf("failed to count phase sales: %w", err)
+		}
+	}
+	return phases, nil
+}
+
+// CreatePricingPhase adds a pricing phase to a tier. Nil bounds leave the
+// phase open on that side.
+func CreatePricingPhase(ctx context.Context, title, name string, price float64, startsAt, endsAt *time.Time, maxQuantity *int) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is not initialized")
+	}
+
+	if _, err := GetCatalogTicket(ctx, title); err != nil {
+		return 0, err
+	}
+
+	var starts, ends any
+	if startsAt != nil {
+		starts = startsAt.UTC().Format(sqliteTime)
+	}
+	if endsAt != nil {
+		ends = endsAt.UTC().Format(sqliteTime)
+	}
+
+	result, err := db.ExecContext(ctx, `INSERT INTO pricing_phases (ticket_title, name, price, starts_at, ends_at, max_quantity) VALUES (?, ?, ?, ?, ?, ?)`, title, name, price, starts, ends, maxQuantity)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert pricing phase: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// DeletePricingPhase removes a pricing phase.
+func DeletePricingPhase(ctx context.Context, id int) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	result, err := db.ExecContext(ctx, `DELETE FROM pricing_phases WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete pricing phase: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrPhaseNotFound
+	}
+	return nil
+}
+
+// ResolvePrice returns the current price of a tier: the cheapest phase that
+// has started, has not ended and still has quantity left, or the catalog
+// price when no phase applies.
+func ResolvePrice(ctx context.Context, title string) (*model.PriceQuote, error) {
+	ticket, err := GetCatalogTicket(ctx, title)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	SELECT name, price, max_quantity
+	FROM pricing_phases
+	WHERE ticket_title = ?
+		AND (starts_at IS NULL OR starts_at <= datetime('now'))
+		AND (ends_at IS NULL OR ends_at > datetime('now'))
+	ORDER BY price, id
+	`
+	rows, err := db.QueryContext(ctx, query, title)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pricing phases: %w", err)
+	}
+
+	type candidate struct {
+		name        string
+		price       float64
+		maxQuantity sql.NullInt64
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.name, &c.price, &c.maxQuantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan pricing phase: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range candidates {
+		if c.maxQuantity.Valid {
+			var sold int64
+			if err := db.QueryRowContext(ctx, phaseSoldQuery, title, c.name).Scan(&sold); err != nil {
+				return nil, fmt.Errorf("failed to count phase sales: %w", err)
+			}
+			if sold >= c.maxQuantity.Int64 {
+				continue
+			}
+		}
+		return &model.PriceQuote{Title: title, Phase: c.name, Price: c.price, BasePrice: ticket.Price}, nil
+	}
+
+	return &model.PriceQuote{Title: title, Price: ticket.Price, BasePrice: ticket.Price}, nil
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reg/internal/model"
+	"sort"
+)
+
+var ErrUnknownReport = errors.New("unknown report")
+
+// Paid tickets exclude complimentary and free ones
+const paidRevenue = `COALESCE(SUM(CASE WHEN pt.is_complimentary = FALSE AND pt.price > 0 THEN pt.price ELSE 0 END), 0)`
+
+var reports = map[string]string{
+	"tiers": `
+	SELECT pt.ticket_title AS tier,
+		COUNT(*) AS tickets,
+		COALESCE(SUM(pt.is_complimentary = TRUE), 0) AS complimentary,
+		` + paidRevenue + ` AS revenue
+	FROM purchased_tickets pt
+	WHERE pt.status = 'active'
+	GROUP BY pt.ticket_title
+	ORDER BY revenue DESC
+	`,
+	"days": `
+	SELECT date(pt.created_at) AS day,
+		COUNT(*) AS tickets,
+		` + paidRevenue + ` AS revenue
+	FROM purchased_tickets pt
+	WHERE pt.status = 'active'
+	GROUP BY day
+	ORDER BY day
+	`,
+	"accommodation": `
+	SELECT CASE WHEN pt.isAccommodation THEN 'yes' ELSE 'no' END AS accommodation,
+		COUNT(*) AS tickets,
+		` + paidRevenue + ` AS revenue
+	FROM purchased_tickets pt
+	WHERE pt.status = 'active'
+	GROUP BY accommodation
+	ORDER BY accommodation
+	`,
+	"coupons": `
+	SELECT t.coupon AS coupon,
+		COUNT(*) AS transactions,
+		COALESCE(SUM(t.is_verified = TRUE), 0) AS verified,
+		COALESCE(SUM(CASE WHEN t.is_verified THEN t.amount ELSE 0 END), 0) AS revenue
+	FROM transactions t
+	WHERE COALESCE(t.coupon, '') != ''
+	GROUP BY t.coupon
+	ORDER BY revenue DESC
+	`,
+	"payments": `
+	SELECT CASE WHEN t.is_verified THEN 'verified' ELSE 'pending' END AS status,
+		COALESCE(t.type, 'purchase') AS type,
+		COUNT(*) AS transactions,
+		COALESCE(SUM(t.amount), 0) AS amount
+	FROM transactions t
+	GROUP BY status, type
+	ORDER BY status, type
+	`,
+	"phases": `
+	SELECT t.ticket_title AS tier,
+		CASE WHEN COALESCE(t.pricing_phase, '') = '' THEN 'catalog' ELSE t.pricing_phase END AS phase,
+		COUNT(*) AS transactions,
+		COALESCE(SUM(CASE WHEN t.is_verified THEN t.amount ELSE 0 END), 0) AS revenue
+	FROM transactions t
+	GROUP BY tier, phase
+	ORDER BY tier, phase
+	`,
+	"conversion": `
+	SELECT COUNT(*) AS signups,
+		COALESCE(SUM(EXISTS(SELECT 1 FROM purchased_tickets pt WHERE pt.user_id = u.id AND pt.status = 'active')), 0) AS buyers,
+		ROUND(100.0 * COALESCE(SUM(EXISTS(SELECT 1 FROM purchased_tickets pt WHERE pt.user_id = u.id AND pt.status = 'active')), 0) / MAX(COUNT(*), 1), 2) AS conversion_percent
+	FROM users u
+	`,
+}
+
+// ReportNames lists the available reports.
+func ReportNames() []string {
+	names := make([]string, 0, len(reports))
+	for name := range reports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetReport runs one of the sales reports.
+func GetReport(ctx context.Context, name string) (*model.Report, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query, ok := reports[name]
+	if !ok {
+		return nil, ErrUnknownReport
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run report %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report columns: %w", err)
+	}
+
+	report := &model.Report{Name: name, Columns: columns, Rows: [][]any{}}
+	for rows.Next() {
+		values := make([]any, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("failed to scan report row: %w", err)
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		report.Rows = append(report.Rows, values)
+	}
+
+	return report, rows.Err()
+}
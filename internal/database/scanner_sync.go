@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reg/internal/model"
+	"sort"
+	"time"
+)
+
+// How far ahead of the server a device clock may be before its timestamps
+// are ignored
+const maxClockSkew = 5 * time.Minute
+
+// ManifestHash is the hash scanners apply to a scanned code to look it up in
+// the manifest: hex encoded, the first 128 bits of SHA-256.
+func ManifestHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:16])
+}
+
+// GetScannerManifest lists the passes a scanner would admit right now so it
+// can keep working without a connection. Gate scanners get every active
+// pass, zone scanners only the passes entitled to their zone.
+func GetScannerManifest(ctx context.Context, scanner model.Scanner) (*model.ScannerManifest, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	manifest := &model.ScannerManifest{
+		GeneratedAt:  time.Now().UTC().Format(time.RFC3339),
+		ScannerID:    scanner.ID,
+		Gate:         scanner.Gate,
+		Zone:         scanner.Zone,
+		HashFunction: "sha256-128",
+		Entitlements: make(map[string][]string),
+		Passes:       []model.ManifestPass{},
+	}
+
+	entitlements, err := GetEntitlements(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entitlements {
+		manifest.Entitlements[e.TicketTitle] = append(manifest.Entitlements[e.TicketTitle], e.Code)
+	}
+
+	// Gate scanners admit once, zone scanners as often as the entitlement
+	// allows, a NULL limit is unlimited and sent as 0
+	query := `
+	SELECT pt.id, pt.pass_code, u.name, pt.ticket_title, pt.isAccommodation,
+		CASE WHEN ? = '' THEN 1 ELSE COALESCE((
+			SELECT MIN(e.max_uses) FROM entitlements e
+			WHERE e.code = ? AND (e.ticket_title = pt.ticket_title OR (e.kind = 'night' AND pt.isAccommodation = TRUE))
+		), 0) END,
+		(SELECT COUNT(*) FROM checkins c WHERE c.ticket_id = pt.id AND c.zone = ? AND c.result = 'admitted')
+	FROM purchased_tickets pt
+	JOIN users u ON u.id = pt.user_id
+	WHERE pt.status = 'active' AND pt.pass_code IS NOT NULL
+		AND (? = '' OR EXISTS (
+			SELECT 1 FROM entitlements e
+			WHERE e.code = ? AND (e.ticket_title = pt.ticket_title OR (e.kind = 'night' AND pt.isAccommodation = TRUE))
+		))
+	ORDER BY pt.id
+	`
+	zone := scanner.Zone
+	rows, err := db.QueryContext(ctx, query, zone, zone, zone, zone, zone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query manifest: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			p    model.ManifestPass
+			code string
+		)
+		if err := rows.Scan(&p.TicketID, &code, &p.Name, &p.TicketTitle, &p.Accommodation, &p.MaxUses, &p.Used); err != nil {
+			return nil, fmt.Errorf("failed to scan manifest pass: %w", err)
+		}
+		p.Hash = ManifestHash(code)
+		manifest.Passes = append(manifest.Passes, p)
+	}
+	return manifest, rows.Err()
+}
+
+// SyncCheckIns records scans a scanner made while offline. Scans are applied
+// in device time order and de-duplicated on their client id, so a device can
+// safely upload the same batch again. A pass already admitted at a different
+// gate is reported as a conflict.
+func SyncCheckIns(ctx context.Context, scanner model.Scanner, scans []model.OfflineScan) ([]model.SyncResult, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	order := make([]int, len(scans))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scans[order[a]].DeviceTime.Before(scans[order[b]].DeviceTime)
+	})
+
+	now := time.Now().UTC()
+	results := make([]model.SyncResult, len(scans))
+	for _, i := range order {
+		scan := scans[i]
+		results[i].ClientID = scan.ClientID
+
+		previous, err := getSyncedCheckIn(ctx, scanner, scan.ClientID)
+		if err == nil {
+			results[i].Duplicate = true
+			results[i].CheckIn = previous
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		// Fall back to the upload time for clocks that are unset or ahead
+		at := scan.DeviceTime.UTC()
+		if at.IsZero() || at.After(now.Add(maxClockSkew)) {
+			at = now
+		}
+
+		checkIn, err := checkIn(ctx, scan.Code, scanner, at, scan.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		results[i].CheckIn = checkIn
+		results[i].Conflict = checkIn.Result == CheckInAlreadyUsed && checkIn.FirstGate != scanner.Gate
+	}
+
+	return results, nil
+}
+
+func getSyncedCheckIn(ctx context.Context, scanner model.Scanner, clientID string) (*model.CheckIn, error) {
+	checkIn := &model.CheckIn{Gate: scanner.Gate, Zone: scanner.Zone}
+	var ticketID sql.NullInt64
+	err := db.QueryRowContext(ctx, `SELECT ticket_id, result, checked_in_at FROM checkins WHERE scanner_id = ? AND client_id = ?`, scanner.ID, clientID).
+		Scan(&ticketID, &checkIn.Result, &checkIn.CheckedInAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to fetch synced check-in: %w", err)
+	}
+	checkIn.TicketID = int(ticketID.Int64)
+	checkIn.Allowed = checkIn.Result == CheckInAdmitted
+	return checkIn, nil
+}
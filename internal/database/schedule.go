@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reg/internal/model"
+	"time"
+)
+
+// EventZone is the time zone of the venue, schedule times are shown in it.
+var EventZone = time.FixedZone("IST", 5*60*60+30*60)
+
+// GetSchedule lists the event days and the sessions in order of time.
+func GetSchedule(ctx context.Context) ([]model.ScheduleEvent, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT code, kind, summary, description, starts_at, ends_at FROM schedule ORDER BY starts_at, code`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedule: %w", err)
+	}
+	defer rows.Close()
+
+	schedule := []model.ScheduleEvent{}
+	for rows.Next() {
+		var (
+			e          model.ScheduleEvent
+			start, end time.Time
+		)
+		if err := rows.Scan(&e.Code, &e.Kind, &e.Summary, &e.Description, &start, &end); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		e.Start = start.In(EventZone)
+		e.End = end.In(EventZone)
+		schedule = append(schedule, e)
+	}
+	return schedule, rows.Err()
+}
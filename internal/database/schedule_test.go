@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetSchedule(t *testing.T) {
+	openTestDB(t)
+
+	schedule, err := GetSchedule(context.Background())
+	if err != nil {
+		t.Fatalf("GetSchedule: %v", err)
+	}
+
+	var codes []string
+	for _, e := range schedule {
+		codes = append(codes, e.Code)
+	}
+	want := []string{"day-1", "networking-dinner", "day-2"}
+	if len(codes) != len(want) {
+		t.Fatalf("schedule = %v, want %v", codes, want)
+	}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Fatalf("schedule = %v, want %v", codes, want)
+		}
+	}
+
+	// Stored in UTC, shown at the venue
+	start := schedule[0].Start
+	if want := time.Date(2025, time.February, 8, 9, 0, 0, 0, EventZone); !start.Equal(want) || start.Location() != EventZone {
+		t.Errorf("day 1 starts at %v, want %v", start, want)
+	}
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reg/internal/model"
+)
+
+var ErrTicketNotFound = errors.New("ticket not found")
+
+// GetCatalog returns every ticket tier on sale, cheapest first.
+func GetCatalog(ctx context.Context) ([]model.Ticket, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT name, description, price, capacity FROM tickets ORDER BY price`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tickets: %w", err)
+	}
+	defer rows.Close()
+
+	var tickets []model.Ticket
+	for rows.Next() {
+		var t model.Ticket
+		if err := rows.Scan(&t.Title, &t.Description, &t.Price, &t.Capacity); err != nil {
+			return nil, fmt.Errorf("failed to scan ticket: %w", err)
+		}
+		tickets = append(tickets, t)
+	}
+
+	return tickets, rows.Err()
+}
+
+// GetCatalogTicket looks up a ticket tier by its title.
+func GetCatalogTicket(ctx context.Context, title string) (*model.Ticket, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	var t model.Ticket
+	err := db.QueryRowContext(ctx, `SELECT name, description, price, capacity FROM tickets WHERE name = ?`, title).
+		Scan(&t.Title, &t.Description, &t.Price, &t.Capacity)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTicketNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch ticket: %w", err)
+	}
+
+	return &t, nil
+}
+
+// GetUserPurchasedTicket returns the most recent ticket owned by the user.
+func GetUserPurchasedTicket(ctx context.Context, userID int) (*model.PurchasedTicket, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	SELECT id, user_id, ticket_title, price, isAccommodation, COALESCE(coupon, ''), created_at
+	FROM purchased_tickets
+	WHERE user_id = ? AND status = 'active'
+	ORDER BY id DESC
+	LIMIT 1
+	`
+	var t model.PurchasedTicket
+	err := db.QueryRowContext(ctx, query, userID).Scan(&t.ID, &t.UserID, &t.TicketTitle, &t.Price, &t.IsAccommodation, &t.Coupon, &t.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTicketNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch purchased ticket: %w", err)
+	}
+
+	return &t, nil
+}
+
+// GetUserTicket returns the owner details and pass id of a purchased ticket.
+func GetUserTicket(ctx context.Context, ticketID int) (*model.UserTicket, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	query := `
+	SELECT u.id, pt.id, u.name, u.email, pt.ticket_title, COALESCE(pt.pass_code, ''), pt.status
+	FROM purchased_tickets pt
+	JOIN users u ON pt.user_id = u.id
+	WHERE pt.id = ?
+	`
+	var ut model.UserTicket
+	err := db.QueryRowContext(ctx, query, ticketID).Scan(&ut.ID, &ut.TicketID, &ut.Name, &ut.Email, &ut.TicketTitle, &ut.UID, &ut.Status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTicketNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch ticket: %w", err)
+	}
+
+	return &ut, nil
+}
@@ -0,0 +1,301 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reg/internal/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pass transfer statuses
+const (
+	TransferPending   = "pending"
+	TransferAccepted  = "accepted"
+	TransferCancelled = "cancelled"
+	TransferExpired   = "expired"
+)
+
+var (
+	ErrTransferNotFound = errors.New("pass transfer not found")
+	ErrTransferPending  = errors.New("a transfer of this pass is already pending")
+	ErrTransferExpired  = errors.New("pass transfer has expired")
+	ErrTransferToSelf   = errors.New("a pass cannot be transferred to its owner")
+	ErrPassCheckedIn    = errors.New("pass has already been used at the gate")
+	ErrUpgradePending   = errors.New("an upgrade of this pass is awaiting verification")
+)
+
+// transferDuration is how long the recipient of a pass has to accept it.
+func transferDuration() time.Duration {
+	if hours, err := strconv.Atoi(os.Getenv("TRANSFER_EXPIRY_HOURS")); err == nil && hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return 72 * time.Hour
+}
+
+const transferColumns = `
+	t.id, t.ticket_id, pt.ticket_title, u.name, u.email, t.to_name, t.to_email, t.status, t.created_at, t.expires_at, t.resolved_at
+	FROM pass_transfers t
+	JOIN purchased_tickets pt ON pt.id = t.ticket_id
+	JOIN users u ON u.id = t.from_user_id
+`
+
+func scanTransfer(row *sql.Row) (*model.PassTransfer, error) {
+	var t model.PassTransfer
+	err := row.Scan(&t.ID, &t.TicketID, &t.TicketTitle, &t.FromName, &t.FromEmail, &t.ToName, &t.ToEmail, &t.Status, &t.CreatedAt, &t.ExpiresAt, &t.ResolvedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTransferNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch pass transfer: %w", err)
+	}
+	return &t, nil
+}
+
+// CreatePassTransfer offers the user's active pass to someone else and
+// returns the transfer with the token the recipient accepts it with. Only a
+// hash of the token is stored. Passes already used at the gate, or with an
+// upgrade awaiting verification, cannot be transferred.
+func CreatePassTransfer(ctx context.Context, userID int, toEmail, toName string) (*model.PassTransfer, string, error) {
+	if db == nil {
+		return nil, "", fmt.Errorf("database connection is not initialized")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var ticketID int
+	var ownerEmail string
+	err = tx.QueryRowContext(ctx, `
+		SELECT pt.id, u.email FROM purchased_tickets pt JOIN users u ON u.id = pt.user_id
+		WHERE pt.user_id = ? AND pt.status = 'active' ORDER BY pt.id DESC LIMIT 1
+	`, userID).Scan(&ticketID, &ownerEmail)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, "", ErrTicketNotFound
+		}
+		return nil, "", fmt.Errorf("failed to fetch ticket: %w", err)
+	}
+	if strings.EqualFold(ownerEmail, toEmail) {
+		return nil, "", ErrTransferToSelf
+	}
+
+	if err := checkTransferable(ctx, tx, ticketID); err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, `UPDATE pass_transfers SET status = 'expired', resolved_at = ? WHERE ticket_id = ? AND status = 'pending' AND expires_at <= ?`, now.Format(sqliteTime), ticketID, now.Format(sqliteTime)); err != nil {
+		return nil, "", fmt.Errorf("failed to expire pass transfers: %w", err)
+	}
+	var pending bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM pass_transfers WHERE ticket_id = ? AND status = 'pending')`, ticketID).Scan(&pending); err != nil {
+		return nil, "", fmt.Errorf("failed to check pending transfers: %w", err)
+	}
+	if pending {
+		return nil, "", ErrTransferPending
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", fmt.Errorf("failed to generate transfer token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO pass_transfers (ticket_id, from_user_id, to_email, to_name, token_hash, expires_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		ticketID, userID, toEmail, toName, hashToken(token), now.Add(transferDuration()).Format(sqliteTime))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to insert pass transfer: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to retrieve last insert ID: %w", err)
+	}
+
+	transfer, err := scanTransfer(tx.QueryRowContext(ctx, `SELECT `+transferColumns+` WHERE t.id = ?`, id))
+	if err != nil {
+		return nil, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return transfer, token, nil
+}
+
+// checkTransferable rejects passes that were used at the gate or have an
+// upgrade awaiting verification, which would otherwise land on the new
+// holder's ticket.
+func checkTransferable(ctx context.Context, q queryRower, ticketID int) error {
+	var checkedIn, upgrading bool
+	err := q.QueryRowContext(ctx, `
+		SELECT
+			EXISTS(SELECT 1 FROM checkins WHERE ticket_id = ? AND zone = '' AND result = 'admitted'),
+			EXISTS(SELECT 1 FROM transactions WHERE type = 'upgrade' AND ticket_id = ? AND is_verified = FALSE)
+	`, ticketID, ticketID).Scan(&checkedIn, &upgrading)
+	if err != nil {
+		return fmt.Errorf("failed to check ticket: %w", err)
+	}
+	if checkedIn {
+		return ErrPassCheckedIn
+	}
+	if upgrading {
+		return ErrUpgradePending
+	}
+	return nil
+}
+
+// GetPendingTransfer returns the transfer the user has offered and the
+// recipient has not accepted yet.
+func GetPendingTransfer(ctx context.Context, userID int) (*model.PassTransfer, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+	return scanTransfer(db.QueryRowContext(ctx, `SELECT `+transferColumns+` WHERE t.from_user_id = ? AND t.status = 'pending' AND t.expires_at > ? ORDER BY t.id DESC LIMIT 1`,
+		userID, time.Now().UTC().Format(sqliteTime)))
+}
+
+// CancelPassTransfer withdraws the user's pending transfer.
+func CancelPassTransfer(ctx context.Context, userID int) (*model.PassTransfer, error) {
+	transfer, err := GetPendingTransfer(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	result, err := db.ExecContext(ctx, `UPDATE pass_transfers SET status = 'cancelled', resolved_at = ? WHERE id = ? AND status = 'pending'`, now.Format(sqliteTime), transfer.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel pass transfer: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrTransferNotFound
+	}
+	transfer.Status = TransferCancelled
+	resolvedAt := now.Format(time.RFC3339)
+	transfer.ResolvedAt = &resolvedAt
+	return transfer, nil
+}
+
+// GetTransferByToken returns the pending transfer a recipient token belongs
+// to, or ErrTransferExpired if it can no longer be accepted.
+func GetTransferByToken(ctx context.Context, token string) (*model.PassTransfer, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+	return transferByToken(ctx, db, token)
+}
+
+func transferByToken(ctx context.Context, q queryRower, token string) (*model.PassTransfer, error) {
+	transfer, err := scanTransfer(q.QueryRowContext(ctx, `SELECT `+transferColumns+` WHERE t.token_hash = ? AND t.status = 'pending'`, hashToken(token)))
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := time.Parse(time.RFC3339, transfer.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse transfer expiry: %w", err)
+	}
+	if !time.Now().Before(expiresAt) {
+		return nil, ErrTransferExpired
+	}
+	return transfer, nil
+}
+
+// AcceptPassTransfer moves the pass to the recipient, creating their account
+// if needed, and issues it a new code so the one the previous holder has
+// stops working. It returns the accepted transfer.
+func AcceptPassTransfer(ctx context.Context, token string) (*model.PassTransfer, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not initialized")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	transfer, err := transferByToken(ctx, tx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	var oldCode sql.NullString
+	err = tx.QueryRowContext(ctx, `
+		SELECT pt.pass_code FROM purchased_tickets pt JOIN pass_transfers t ON t.ticket_id = pt.id
+		WHERE t.id = ? AND pt.user_id = t.from_user_id AND pt.status = 'active'
+	`, transfer.ID).Scan(&oldCode)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTicketNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch ticket: %w", err)
+	}
+	if err := checkTransferable(ctx, tx, transfer.TicketID); err != nil {
+		return nil, err
+	}
+
+	toUserID, err := findOrCreateUser(ctx, tx, transfer.ToEmail, transfer.ToName, "")
+	if err != nil {
+		return nil, err
+	}
+	var hasTicket bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM purchased_tickets WHERE user_id = ? AND status = 'active')`, toUserID).Scan(&hasTicket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing tickets: %w", err)
+	}
+	if hasTicket {
+		return nil, ErrAlreadyHasTicket
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE purchased_tickets SET user_id = ? WHERE id = ?`, toUserID, transfer.TicketID); err != nil {
+		return nil, fmt.Errorf("failed to reassign ticket: %w", err)
+	}
+	if _, err := issuePassCode(ctx, tx, int64(transfer.TicketID)); err != nil {
+		return nil, err
+	}
+
+	// The room was picked for the previous holder
+	if _, err := tx.ExecContext(ctx, `DELETE FROM room_allocations WHERE ticket_id = ? AND status = 'allocated'`, transfer.TicketID); err != nil {
+		return nil, fmt.Errorf("failed to release room: %w", err)
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.ExecContext(ctx, `UPDATE pass_transfers SET status = 'accepted', to_user_id = ?, old_pass_code = ?, resolved_at = ? WHERE id = ?`,
+		toUserID, oldCode, now.Format(sqliteTime), transfer.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update pass transfer: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Ticket %d transferred from %s to %s", transfer.TicketID, transfer.FromEmail, transfer.ToEmail)
+	transfer.Status = TransferAccepted
+	resolvedAt := now.Format(time.RFC3339)
+	transfer.ResolvedAt = &resolvedAt
+	return transfer, nil
+}
+
+// transferredTicket returns the ticket a pass code belonged to before the
+// pass was transferred.
+func transferredTicket(ctx context.Context, code string) (int, bool, error) {
+	var ticketID int
+	err := db.QueryRowContext(ctx, `SELECT ticket_id FROM pass_transfers WHERE old_pass_code = ? AND status = 'accepted' LIMIT 1`, code).Scan(&ticketID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to check transferred passes: %w", err)
+	}
+	return ticketID, true, nil
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reg/internal/model"
+	"testing"
+)
+
+// offerTestTransfer has the owner of a PREMIUM pass offer it to
+// friend@example.com and returns the pass and the token of the offer.
+func offerTestTransfer(t *testing.T) (*model.UserTicket, string) {
+	t.Helper()
+	ticket := createTestTicket(t, "owner@example.com", "PREMIUM")
+	_, token, err := CreatePassTransfer(context.Background(), ticket.ID, "friend@example.com", "Friend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ticket, token
+}
+
+// acceptFails accepts the offer expecting want and checks the pass stayed
+// with its owner under the same code.
+func acceptFails(t *testing.T, ticket *model.UserTicket, token string, want error) {
+	t.Helper()
+	if _, err := AcceptPassTransfer(context.Background(), token); !errors.Is(err, want) {
+		t.Fatalf("got error %v, want %v", err, want)
+	}
+	after, err := GetUserTicket(context.Background(), ticket.TicketID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Email != ticket.Email || after.UID != ticket.UID {
+		t.Errorf("failed transfer changed the pass: owner %s, code changed %v", after.Email, after.UID != ticket.UID)
+	}
+}
+
+func TestAcceptPassTransfer(t *testing.T) {
+	ctx := context.Background()
+	openTestDB(t)
+	ticket, token := offerTestTransfer(t)
+
+	if _, err := AcceptPassTransfer(ctx, token); err != nil {
+		t.Fatalf("AcceptPassTransfer: %v", err)
+	}
+
+	after, err := GetUserTicket(ctx, ticket.TicketID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Email != "friend@example.com" {
+		t.Fatalf("got owner %s, want friend@example.com", after.Email)
+	}
+	if after.UID == ticket.UID {
+		t.Fatal("pass code was not replaced")
+	}
+	if _, err := VerifyPassCode(ctx, ticket.UID); !errors.Is(err, ErrInvalidPass) {
+		t.Errorf("old code: got error %v, want %v", err, ErrInvalidPass)
+	}
+	if verified, err := VerifyPassCode(ctx, after.UID); err != nil || verified.TicketID != ticket.TicketID {
+		t.Errorf("new code: got %+v, %v", verified, err)
+	}
+
+	scanner := testScanner(t, "")
+	checkIn, err := CheckIn(ctx, ticket.UID, *scanner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkIn.Result != CheckInRevoked || checkIn.Name != "" {
+		t.Errorf("old code: got result %s for %q, want %s without a name", checkIn.Result, checkIn.Name, CheckInRevoked)
+	}
+	if checkIn, err = CheckIn(ctx, after.UID, *scanner); err != nil || checkIn.Result != CheckInAdmitted {
+		t.Errorf("new code: got %+v, %v", checkIn, err)
+	}
+
+	if _, err := AcceptPassTransfer(ctx, token); !errors.Is(err, ErrTransferNotFound) {
+		t.Errorf("accepting again: got error %v, want %v", err, ErrTransferNotFound)
+	}
+}
+
+func TestAcceptPassTransferRecipientHasPass(t *testing.T) {
+	openTestDB(t)
+	ticket, token := offerTestTransfer(t)
+	if err := AddBasicTickets(createTestUser(t, "friend@example.com"), "STANDARD"); err != nil {
+		t.Fatal(err)
+	}
+
+	acceptFails(t, ticket, token, ErrAlreadyHasTicket)
+}
+
+func TestAcceptPassTransferExpired(t *testing.T) {
+	openTestDB(t)
+	ticket, token := offerTestTransfer(t)
+	if _, err := db.Exec(`UPDATE pass_transfers SET expires_at = '2000-01-01 00:00:00'`); err != nil {
+		t.Fatal(err)
+	}
+
+	acceptFails(t, ticket, token, ErrTransferExpired)
+}
+
+func TestAcceptPassTransferCancelled(t *testing.T) {
+	openTestDB(t)
+	ticket, token := offerTestTransfer(t)
+	if _, err := CancelPassTransfer(context.Background(), ticket.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	acceptFails(t, ticket, token, ErrTransferNotFound)
+}
+
+func TestAcceptPassTransferUsedAtTheGate(t *testing.T) {
+	openTestDB(t)
+	ticket, token := offerTestTransfer(t)
+	if _, err := CheckIn(context.Background(), ticket.UID, *testScanner(t, "")); err != nil {
+		t.Fatal(err)
+	}
+
+	acceptFails(t, ticket, token, ErrPassCheckedIn)
+}
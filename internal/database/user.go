@@ -20,10 +20,10 @@ func CreateUser(ctx context.Context, user model.User) (int64, error) {
 	}
 
 	query := `
-    INSERT INTO users (email, name, contact_number, data)
-    VALUES (?, ?, ?, ?)
+    INSERT INTO users (email, name, contact_number, data, gender)
+    VALUES (?, ?, ?, ?, ?)
     `
-	result, err := db.ExecContext(ctx, query, user.Email, user.Name, user.ContactNumber, string(dataJSON))
+	result, err := db.ExecContext(ctx, query, user.Email, user.Name, user.ContactNumber, string(dataJSON), user.Gender)
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert user: %w", err)
 	}
@@ -126,18 +126,19 @@ func GetMeUser(ctx context.Context, id int64) (*model.User, int, error) {
     	u.name,
     	u.email,
     	u.contact_number,
+    	u.gender,
     	COALESCE(pt.id, '-1') AS ticket_id
 	FROM 
     	users u
 	LEFT JOIN 
-    	purchased_tickets pt ON u.id = pt.user_id 
+    	purchased_tickets pt ON u.id = pt.user_id AND pt.status = 'active' 
 	WHERE u.id = ?;
 	`
 	row := db.QueryRowContext(ctx, query, id)
 
 	var user model.User
 	var ticketID int
-	err := row.Scan( &user.ID ,&user.Name, &user.Email, &user.ContactNumber, &ticketID)
+	err := row.Scan( &user.ID ,&user.Name, &user.Email, &user.ContactNumber, &user.Gender, &ticketID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, -1, fmt.Errorf("no user found")
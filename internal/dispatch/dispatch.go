@@ -0,0 +1,137 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reg/internal/database"
+	email "reg/internal/emails"
+	"reg/internal/model"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Passes claimed from the database at a time
+const batchSize = 50
+
+var ErrJobRunning = errors.New("a dispatch job is already running")
+
+var (
+	mu      sync.Mutex
+	running bool
+)
+
+// SendPass queues a ticket's pass in the outbox and records it, so dispatch
+// jobs skip passes that were already queued or delivered.
+func SendPass(ticket model.UserTicket) (bool, error) {
+	return sendPass(ticket, nil)
+}
+
+func sendPass(ticket model.UserTicket, jobID *int64) (bool, error) {
+	room, err := database.GetTicketAllocation(context.Background(), ticket.TicketID)
+	if err != nil && !errors.Is(err, database.ErrAllocationNotFound) {
+		fmt.Println(err)
+	}
+
+	ok, err := email.SendTicketPass(ticket, room)
+	if !ok && err == nil {
+		err = errors.New("email not sent")
+	}
+	if recordErr := database.RecordPassDispatch(context.Background(), ticket.TicketID, jobID, err); recordErr != nil {
+		fmt.Println(recordErr)
+	}
+	return ok, err
+}
+
+// interval is the pause between two emails of a job, set by
+// DISPATCH_INTERVAL_MS to stay under the SMTP provider's rate limit.
+func interval() time.Duration {
+	if ms, err := strconv.Atoi(os.Getenv("DISPATCH_INTERVAL_MS")); err == nil && ms >= 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return 200 * time.Millisecond
+}
+
+// Recover closes jobs a previous process left running. Passes they were
+// sending are picked up again by the next job.
+func Recover() {
+	if err := database.InterruptDispatchJobs(context.Background()); err != nil {
+		log.Printf("Failed to recover dispatch jobs: %v", err)
+	}
+}
+
+// Start begins mailing every pass that has not been sent yet, including
+// failed ones, in the background. Passes already sent are skipped, so
+// starting again after an interruption carries on where it stopped.
+func Start(startedBy string) (*model.DispatchJob, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if running {
+		return nil, ErrJobRunning
+	}
+
+	total, err := database.CountPassesToDispatch(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	jobID, err := database.CreateDispatchJob(context.Background(), startedBy, total)
+	if err != nil {
+		return nil, err
+	}
+	job, err := database.GetDispatchJob(context.Background(), jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	running = true
+	go run(jobID)
+	return job, nil
+}
+
+func run(jobID int64) {
+	defer func() {
+		mu.Lock()
+		running = false
+		mu.Unlock()
+	}()
+
+	ctx := context.Background()
+	pause := interval()
+	sent, failed := 0, 0
+	status := database.JobCompleted
+
+	log.Printf("Pass dispatch job %d started", jobID)
+	for {
+		tickets, err := database.ClaimPassesToDispatch(ctx, jobID, batchSize)
+		if err != nil {
+			log.Printf("Pass dispatch job %d stopped: %v", jobID, err)
+			status = database.JobInterrupted
+			break
+		}
+		if len(tickets) == 0 {
+			break
+		}
+
+		for _, ticket := range tickets {
+			if ok, err := sendPass(ticket, &jobID); !ok {
+				fmt.Printf("Failed to send email to %s, ERR: %s\n", ticket.Email, err)
+				failed++
+			} else {
+				sent++
+			}
+			time.Sleep(pause)
+		}
+
+		if err := database.UpdateDispatchJob(ctx, jobID, sent, failed, database.JobRunning); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	if err := database.UpdateDispatchJob(ctx, jobID, sent, failed, status); err != nil {
+		fmt.Println(err)
+	}
+	log.Printf("Pass dispatch job %d %s: %d sent, %d failed", jobID, status, sent, failed)
+}
@@ -1,20 +1,15 @@
 package email
 
 import (
-	"bytes"
-	"encoding/base64"
-	"fmt"
-	"html/template"
-	"image/png"
+	"context"
 	"log"
-	"net/smtp"
 	"os"
-	"reg/internal/config"
+	"reg/internal/calendar"
+	"reg/internal/database"
 	"reg/internal/model"
-	"strings"
-
-	"github.com/boombuler/barcode"
-	"github.com/boombuler/barcode/code128"
+	"reg/internal/passes"
+	"reg/internal/wallet"
+	"reg/templates"
 )
 
 var (
@@ -23,248 +18,220 @@ var (
 	smtpUser = os.Getenv("SMTP_USER")
 )
 
-func SendPASSEmail(to string, cc []string, subject string, body []byte, replyto string, qrCodeId string) (bool, error) {
-	fromName := "E-Summit x E-Cell IIT Hyderabad"
-	from := smtpUser
-
-	// Setup headers
-	headers := make(map[string]string)
-	headers["From"] = fmt.Sprintf("%s <%s>", fromName, from)
-	if replyto != "" {
-		headers["Reply-To"] = replyto
-	}
-	headers["To"] = to
-	if len(cc) > 0 {
-		headers["Cc"] = strings.Join(cc, ",")
-	}
-	headers["Subject"] = subject
-	headers["MIME-Version"] = "1.0"
-	headers["Content-Type"] = "multipart/related; boundary=boundary42"
+const fromName = "E-Summit x E-Cell IIT Hyderabad"
 
-	// Setup message
-	var msg bytes.Buffer
-	for k, v := range headers {
-		msg.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
+func newMessage(to string, cc []string, subject string, body Body, replyto string) *Message {
+	return &Message{
+		FromName:    fromName,
+		From:        smtpUser,
+		To:          to,
+		Cc:          cc,
+		ReplyTo:     replyto,
+		Subject:     subject,
+		HTML:        body.HTML,
+		Template:    body.Template,
+		Attachments: body.Attachments,
 	}
-	msg.WriteString("\r\n--boundary42\r\n")
-	msg.WriteString("Content-Type: text/html; charset=\"utf-8\"\r\n\r\n")
-	msg.Write(body)
+}
 
-	// Attach image.png
-	imagePath := "templates/image.png"
-	imageData, err := os.ReadFile(imagePath)
-	if err != nil {
-		log.Printf("Failed to read image file: %v\n", err)
+// queue builds a message and stores it in the outbox. ticketID links a pass
+// email to its ticket.
+func queue(m *Message, ticketID *int) (bool, error) {
+	if _, err := queueMessage(m, ticketID); err != nil {
 		return false, err
 	}
-	msg.WriteString("\r\n--boundary42\r\n")
-	msg.WriteString("Content-Type: image/png\r\n")
-	msg.WriteString("Content-Transfer-Encoding: base64\r\n")
-	msg.WriteString("Content-ID: <image.png>\r\n\r\n")
-	msg.WriteString(base64.StdEncoding.EncodeToString(imageData))
+	return true, nil
+}
 
-	// Attach QR code
-	qrCodeData, err := generateBarcodeBase64(qrCodeId)
+func queueMessage(m *Message, ticketID *int) (int64, error) {
+	e, err := outboxEmail(m, ticketID)
 	if err != nil {
-		log.Printf("Failed to generate QR code: %v\n", err)
-		return false, err
+		return 0, err
 	}
-	msg.WriteString("\r\n--boundary42\r\n")
-	msg.WriteString("Content-Type: image/png\r\n")
-	msg.WriteString("Content-Transfer-Encoding: base64\r\n")
-	msg.WriteString("Content-ID: <qrcode.png>\r\n\r\n")
-	msg.WriteString(qrCodeData)
-
-	msg.WriteString("\r\n--boundary42--")
-
-	// Recipients
-	recipients := append([]string{to}, cc...)
+	return enqueue(e)
+}
 
-	// Sending email
-	err = smtp.SendMail(smtpHost+":"+smtpPort, config.SmtpAuth, from, recipients, msg.Bytes())
+// outboxEmail builds the outbox row of a message.
+func outboxEmail(m *Message, ticketID *int) (model.OutboxEmail, error) {
+	data, err := m.Bytes()
 	if err != nil {
-		log.Printf("Failed to send email: %v\n", err)
-		config.LogEmails(to, cc, subject, false)
-		return false, err
-	}
-	config.LogEmails(to, cc, subject, true)
-	return true, nil
+		log.Printf("Failed to build email: %v\n", err)
+		return model.OutboxEmail{}, err
+	}
+	return model.OutboxEmail{
+		From:       m.From,
+		Recipients: m.Recipients(),
+		To:         m.To,
+		Subject:    m.Subject,
+		Template:   m.Template,
+		Message:    data,
+		TicketID:   ticketID,
+	}, nil
 }
 
-func SendEmail(to string, cc []string, subject string, body []byte, replyto string) (bool, error) {
-	fromName := "E-Summit x E-Cell IIT Hyderabad"
-	from := smtpUser
-	// Setup headers
-	headers := make(map[string]string)
-	headers["From"] = fmt.Sprintf("%s <%s>", fromName, from)
-	if replyto != "" {
-		headers["Reply-To"] = replyto
+// QueueCampaignEmail renders a campaign template for one recipient and
+// queues it, marking the recipient as queued in the same transaction. It
+// returns the outbox id of the email. The email carries a one-click link to
+// unsubscribe userID from category and is not queued without one.
+func QueueCampaignEmail(campaignID, userID int, to, subject, template, category string, data CampaignData) (int64, error) {
+	data.UnsubscribeURL = UnsubscribeURL(userID, category)
+	if data.UnsubscribeURL == "" {
+		return 0, ErrUnsubscribeUnavailable
 	}
-	headers["To"] = to
-	if len(cc) > 0 {
-		headers["Cc"] = strings.Join(cc, ",")
+	body, err := renderBody(template+".html", data)
+	if err != nil {
+		return 0, err
 	}
-	headers["Subject"] = subject
-	headers["MIME-Version"] = "1.0"
-	headers["Content-Type"] = "text/html; charset=\"utf-8\""
+	m := newMessage(to, nil, subject, body, "")
+	// RFC 8058, mail clients POST to the link to unsubscribe in one click
+	m.Headers = append(m.Headers,
+		Header{Name: "List-Unsubscribe", Value: "<" + data.UnsubscribeURL + ">"},
+		Header{Name: "List-Unsubscribe-Post", Value: "List-Unsubscribe=One-Click"},
+	)
 
-	// Setup message
-	var msg bytes.Buffer
-	for k, v := range headers {
-		msg.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
+	e, err := outboxEmail(m, nil)
+	if err != nil {
+		return 0, err
 	}
-	msg.WriteString("\r\n")
-	msg.Write(body)
+	return enqueueWith(e, func(ctx context.Context, e model.OutboxEmail) (int64, error) {
+		return database.QueueCampaignEmail(ctx, campaignID, userID, e)
+	})
+}
 
-	// Recipients
-	recipients := append([]string{to}, cc...)
+// RenderCampaignEmail renders a campaign template, for previews.
+func RenderCampaignEmail(template string, data CampaignData) ([]byte, error) {
+	return render(template+".html", data)
+}
 
-	// Sending email
-	err := smtp.SendMail(smtpHost+":"+smtpPort, config.SmtpAuth, from, recipients, msg.Bytes())
+// SendPASSEmail queues an email with the pass image of qrCodeId attached.
+func SendPASSEmail(to string, cc []string, subject string, body Body, replyto string, qrCodeId string) (bool, error) {
+	m, err := passMessage(to, cc, subject, body, replyto, qrCodeId)
 	if err != nil {
-		log.Printf("Failed to send email: %v\n", err)
-		config.LogEmails(to, cc, subject, false)
 		return false, err
 	}
-	config.LogEmails(to, cc, subject, true)
-	log.Println("Email sent successfully!")
-	return true, nil
+	return queue(m, nil)
 }
 
-func LoadOtpVerificationsTemplate(otp string) ([]byte, error) {
-	filePath := "templates/otp.html"
-	template, err := os.ReadFile(filePath)
+// passMessage is an email showing the E-Summit banner and the pass image
+// of qrCodeId inline.
+func passMessage(to string, cc []string, subject string, body Body, replyto string, qrCodeId string) (*Message, error) {
+	imageData, err := templates.FS.ReadFile("image.png")
 	if err != nil {
+		log.Printf("Failed to read image file: %v\n", err)
 		return nil, err
 	}
-
-	// Replace placeholders in the template with actual data
-	htmlContent := string(template)
-	htmlContent = strings.ReplaceAll(htmlContent, "[OTP]", otp)
-
-	return []byte(htmlContent), nil
-}
-
-func LoadRegistrationTemplate(data model.RegistrationRequest) ([]byte, error) {
-	filePath := "templates/register.html"
-	tmplContent, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, err
-	}
-
-	tmpl, err := template.New("registration").Parse(string(tmplContent))
+	qrCodeData, err := passes.PNG(qrCodeId)
 	if err != nil {
+		log.Printf("Failed to generate QR code: %v\n", err)
 		return nil, err
 	}
 
-	var renderedContent bytes.Buffer
+	m := newMessage(to, cc, subject, body, replyto)
+	m.Attachments = append(m.Attachments,
+		Attachment{Filename: "image.png", ContentType: "image/png", ContentID: "image.png", Data: imageData},
+		Attachment{Filename: "qrcode.png", ContentType: "image/png", ContentID: "qrcode.png", Data: qrCodeData},
+	)
+	return m, nil
+}
 
-	// Execute the template with the registration data and write to the buffer
-	err = tmpl.Execute(&renderedContent, data)
+// SendTicketPass queues the pass for a purchased ticket to its owner, with
+// their room when one has been allocated. The outbox records its delivery
+// against the ticket.
+func SendTicketPass(ticket model.UserTicket, room *model.RoomAllocation) (bool, error) {
+	m, err := ticketPassMessage(ticket, room)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-
-	// Return the rendered HTML content as a byte slice
-	return renderedContent.Bytes(), nil
+	return queue(m, &ticket.TicketID)
 }
 
-func LoadSignUpVerificationTemplate(name string) ([]byte, error) {
-	filePath := "templates/signup.html"
-	tmplContent, err := os.ReadFile(filePath)
+func ticketPassMessage(ticket model.UserTicket, room *model.RoomAllocation) (*Message, error) {
+	data, err := LoadPassEmailTemplate(ticket.Name, ticket.TicketTitle, ticket.UID, room)
 	if err != nil {
 		return nil, err
 	}
+	return passMessage(ticket.Email, nil, "Your E-Summit 2025 Pass & Event Schedule Are Here!", data, "", ticket.UID)
+}
 
-	htmlContent := string(tmplContent)
-	htmlContent = strings.ReplaceAll(htmlContent, "{{.name}}", name)
-
-	return []byte(htmlContent), nil
+// SendEmail queues an HTML email in the outbox, it is delivered by the
+// outbox workers.
+func SendEmail(to string, cc []string, subject string, body Body, replyto string) (bool, error) {
+	return queue(newMessage(to, cc, subject, body, replyto), nil)
 }
 
-func LoadPurchasedTicketTemplate(name, title, amount string) ([]byte, error) {
-	filePath := "templates/tickets_purchased.html"
-	tmplContent, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, err
-	}
+func LoadOtpVerificationsTemplate(otp string) (Body, error) {
+	return renderBody("otp.html", OTPData{OTP: otp})
+}
 
-	htmlContent := string(tmplContent)
-	htmlContent = strings.ReplaceAll(htmlContent, "{{.Name}}", name)
-	htmlContent = strings.ReplaceAll(htmlContent, "{{.TicketType}}", title)
-	htmlContent = strings.ReplaceAll(htmlContent, "{{.Price}}", amount)
+func LoadRegistrationTemplate(data model.RegistrationRequest) (Body, error) {
+	return renderBody("register.html", data)
+}
 
-	return []byte(htmlContent), nil
+func LoadSignUpVerificationTemplate(name string) (Body, error) {
+	return renderBody("signup.html", SignUpData{Name: name})
 }
 
-func LoadPendingTemplate(name, txnId, amount string) ([]byte, error) {
-	filePath := "templates/pending.html"
-	tmplContent, err := os.ReadFile(filePath)
+// LoadPurchasedTicketTemplate renders the purchase confirmation, with the
+// calendar of the tier bought attached.
+func LoadPurchasedTicketTemplate(name, title, amount string) (Body, error) {
+	body, err := renderBody("tickets_purchased.html", TicketPurchasedData{Name: name, TicketType: title, Price: amount})
 	if err != nil {
-		return nil, err
+		return body, err
 	}
+	body.Attachments = calendarAttachments(title)
+	return body, nil
+}
 
-	htmlContent := string(tmplContent)
-	htmlContent = strings.ReplaceAll(htmlContent, "{{.Name}}", name)
-	htmlContent = strings.ReplaceAll(htmlContent, "{{.TransactionID}}", txnId)
-	htmlContent = strings.ReplaceAll(htmlContent, "{{.Amount}}", amount)
+func LoadPendingTemplate(name, txnId, amount string) (Body, error) {
+	return renderBody("pending.html", PendingData{Name: name, TransactionID: txnId, Amount: amount})
+}
 
-	return []byte(htmlContent), nil
+func LoadWaitlistPromotionTemplate(name, title, expiresAt string) (Body, error) {
+	return renderBody("waitlist.html", WaitlistData{Name: name, TicketType: title, ExpiresAt: expiresAt})
 }
 
-func generateBarcodeBase64(data string) (string, error) {
-	// Generate a Code128 barcode
-	barCode, err := code128.Encode(data)
-	if err != nil {
-		return "", err
-	}
+func LoadTransferOfferTemplate(name, fromName, title, acceptURL, expiresAt string) (Body, error) {
+	return renderBody("transfer_offer.html", TransferOfferData{Name: name, FromName: fromName, TicketType: title, AcceptURL: acceptURL, ExpiresAt: expiresAt})
+}
 
-	// Scale the barcode to a desired size
-	scaledBarCode, err := barcode.Scale(barCode, 1024, 200) // Width x Height
-	if err != nil {
-		return "", err
-	}
+func LoadTransferCompleteTemplate(name, toName, title string) (Body, error) {
+	return renderBody("transfer_complete.html", TransferCompleteData{Name: name, ToName: toName, TicketType: title})
+}
 
-	// Encode the barcode to PNG
-	var buf bytes.Buffer
-	err = png.Encode(&buf, scaledBarCode)
+// LoadPassEmailTemplate renders the pass email, with the holder's room when
+// one has been allocated and add to wallet buttons when a wallet is
+// configured. The calendar of the pass's tier is attached.
+func LoadPassEmailTemplate(name, pass, id string, room *model.RoomAllocation) (Body, error) {
+	apple, google := wallet.Links(id)
+	body, err := renderBody("pass.html", PassData{
+		Name:            name,
+		Pass:            pass,
+		Code:            id,
+		AppleWalletURL:  apple,
+		GoogleWalletURL: google,
+		Accommodation:   room,
+	})
 	if err != nil {
-		return "", err
+		return body, err
 	}
-
-	// Convert to Base64
-	barBase64 := base64.StdEncoding.EncodeToString(buf.Bytes())
-	return barBase64, nil
+	body.Attachments = calendarAttachments(pass)
+	return body, nil
 }
 
-func LoadPassEmailTemplate(name, pass, id string) ([]byte, error) {
-	filePath := "templates/pass.html"
-	tmplContent, err := os.ReadFile(filePath)
+// calendarAttachments is the .ics file of the event days and the sessions
+// of a tier. An email goes out without it rather than not at all when the
+// tier's sessions cannot be read.
+func calendarAttachments(tier string) []Attachment {
+	events, err := calendar.ForTier(context.Background(), tier)
 	if err != nil {
-		fmt.Printf("Error reading template file: %v\n", err)
-		return nil, err
-	}
-
-	htmlContent := string(tmplContent)
-	htmlContent = strings.ReplaceAll(htmlContent, "{{.Name}}", name)
-	htmlContent = strings.ReplaceAll(htmlContent, "{{.Pass}}", pass)
-
-	// data, err := generateBarcodeBase64(id)
-	// if err != nil {
-	// 	fmt.Printf("Failed to generate QR code: %v for id %s\n", err, id)
-	// 	return nil, err
-	// }
-	// htmlContent = strings.ReplaceAll(htmlContent, "{{.QRDATA}}", "data:image/png;base64,"+data)
-	htmlContent = strings.ReplaceAll(htmlContent, "{{.ALT}}", id)
-
-	// imageBase64, err := loadImageBase64("templates/image.png")
-	// if err != nil {
-	// 	fmt.Printf("Failed to load image: %v\n", err)
-	// 	return nil, err
-	// }
-	// htmlContent = strings.ReplaceAll(htmlContent, "{{.ImageBase64}}", imageBase64)
-
-	return []byte(htmlContent), nil
+		log.Printf("Failed to build calendar for %s: %v\n", tier, err)
+		return nil
+	}
+	return []Attachment{{
+		Filename:    "esummit-2025.ics",
+		ContentType: "text/calendar; charset=utf-8; method=PUBLISH",
+		Data:        calendar.ICS(events),
+	}}
 }
 
 // func loadImageBase64(filePath string) (string, error) {
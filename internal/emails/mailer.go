@@ -0,0 +1,136 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"reg/internal/config"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Mailer delivers a built message to its recipients.
+type Mailer interface {
+	Send(from string, recipients []string, message []byte) error
+}
+
+// SMTPMailer sends through a mail server.
+type SMTPMailer struct {
+	Addr string
+	Auth smtp.Auth
+}
+
+func (m SMTPMailer) Send(from string, recipients []string, message []byte) error {
+	return smtp.SendMail(m.Addr, m.Auth, from, recipients, message)
+}
+
+// FileMailer writes every message to Dir as an .eml file instead of sending
+// it, for local development.
+type FileMailer struct {
+	Dir string
+}
+
+var fileSeq atomic.Int64
+
+func (m FileMailer) Send(from string, recipients []string, message []byte) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	// Written under a temporary name and renamed, so a reader watching the
+	// directory never sees half a message.
+	name := fmt.Sprintf("%d-%d", time.Now().UnixNano(), fileSeq.Add(1))
+	tmp := filepath.Join(m.Dir, "."+name+".tmp")
+	envelope := fmt.Sprintf("X-Envelope-From: %s\r\nX-Envelope-To: %s\r\n", from, strings.Join(recipients, ", "))
+	if err := os.WriteFile(tmp, append([]byte(envelope), message...), 0o644); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(m.Dir, name+".eml"))
+}
+
+// SentMessage is a message recorded by a MemoryMailer.
+type SentMessage struct {
+	From       string
+	Recipients []string
+	Message    []byte
+}
+
+// MemoryMailer keeps every message in memory, for tests to assert on.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []SentMessage
+}
+
+func (m *MemoryMailer) Send(from string, recipients []string, message []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, SentMessage{
+		From:       from,
+		Recipients: append([]string(nil), recipients...),
+		Message:    append([]byte(nil), message...),
+	})
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (m *MemoryMailer) Messages() []SentMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]SentMessage(nil), m.messages...)
+}
+
+// Reset forgets the messages sent so far.
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = nil
+}
+
+var (
+	mailerMu sync.RWMutex
+	mailer   Mailer
+)
+
+// SetMailer replaces the transport the outbox delivers through.
+func SetMailer(m Mailer) {
+	mailerMu.Lock()
+	defer mailerMu.Unlock()
+	mailer = m
+}
+
+func send(from string, recipients []string, message []byte) error {
+	mailerMu.RLock()
+	m := mailer
+	mailerMu.RUnlock()
+	if m == nil {
+		return errors.New("mail transport is not initialized")
+	}
+	return m.Send(from, recipients, message)
+}
+
+// InitMailer picks the transport named by MAIL_TRANSPORT: "smtp" (the
+// default) sends through SMTP_HOST, "file" writes .eml files to MAIL_DIR and
+// "memory" keeps messages in memory. Call it after config.InitSMTP.
+func InitMailer() {
+	switch transport := os.Getenv("MAIL_TRANSPORT"); transport {
+	case "", "smtp":
+		SetMailer(SMTPMailer{Addr: smtpHost + ":" + smtpPort, Auth: config.SmtpAuth})
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		SetMailer(FileMailer{Dir: dir})
+		log.Printf("Emails are written to %s instead of being sent", dir)
+	case "memory":
+		SetMailer(&MemoryMailer{})
+		log.Println("Emails are kept in memory instead of being sent")
+	default:
+		log.Fatalf("Unknown MAIL_TRANSPORT %q", transport)
+	}
+}
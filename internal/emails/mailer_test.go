@@ -0,0 +1,54 @@
+package email
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailerWritesEML(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m := FileMailer{Dir: dir}
+	message := []byte("Subject: Hi\r\n\r\nHello")
+	if err := m.Send("from@x.com", []string{"a@x.com", "b@x.com"}, message); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("got %v files, err %v", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "X-Envelope-To: a@x.com, b@x.com\r\n") || !bytes.HasSuffix(data, message) {
+		t.Fatalf("unexpected file contents %q", data)
+	}
+}
+
+func TestMemoryMailerRecords(t *testing.T) {
+	m := &MemoryMailer{}
+	SetMailer(m)
+	t.Cleanup(func() { SetMailer(nil) })
+
+	if err := send("from@x.com", []string{"a@x.com"}, []byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	sent := m.Messages()
+	if len(sent) != 1 || sent[0].From != "from@x.com" || string(sent[0].Message) != "one" {
+		t.Fatalf("unexpected messages %+v", sent)
+	}
+
+	m.Reset()
+	if len(m.Messages()) != 0 {
+		t.Fatal("messages kept after reset")
+	}
+
+	SetMailer(nil)
+	if err := send("from@x.com", []string{"a@x.com"}, []byte("two")); err == nil {
+		t.Fatal("sent without a transport")
+	}
+}
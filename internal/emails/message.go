@@ -0,0 +1,279 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Attachment is a file sent with a message. One with a ContentID is shown
+// inline, referenced from the HTML as cid:ContentID.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	ContentID   string
+	Data        []byte
+}
+
+// Header is an extra header of a message, kept in the order given.
+type Header struct {
+	Name  string
+	Value string
+}
+
+// Message is an email to build. Text is generated from HTML when empty.
+// Template names the template HTML was rendered from, it is recorded with
+// the email but not sent.
+type Message struct {
+	FromName    string
+	From        string
+	To          string
+	Cc          []string
+	ReplyTo     string
+	Subject     string
+	HTML        []byte
+	Text        string
+	Headers     []Header
+	Attachments []Attachment
+	Template    string
+}
+
+// Recipients are the addresses the message is delivered to.
+func (m *Message) Recipients() []string {
+	return append([]string{m.To}, m.Cc...)
+}
+
+// Bytes builds the message: multipart/alternative text and HTML, wrapped in
+// multipart/related when there are inline images and in multipart/mixed
+// when there are attachments.
+func (m *Message) Bytes() ([]byte, error) {
+	var inline, attached []Attachment
+	for _, a := range m.Attachments {
+		if a.ContentID != "" {
+			inline = append(inline, a)
+		} else {
+			attached = append(attached, a)
+		}
+	}
+
+	text := m.Text
+	if text == "" {
+		text = htmlToText(string(m.HTML))
+	}
+
+	var body entity = alternative{text: text, html: m.HTML}
+	if len(inline) > 0 {
+		body = multipartEntity{subtype: "related", parts: append([]entity{body}, attachmentEntities(inline)...)}
+	}
+	if len(attached) > 0 {
+		body = multipartEntity{subtype: "mixed", parts: append([]entity{body}, attachmentEntities(attached)...)}
+	}
+
+	var msg bytes.Buffer
+	writeHeader(&msg, "From", (&mail.Address{Name: m.FromName, Address: m.From}).String())
+	if m.ReplyTo != "" {
+		writeHeader(&msg, "Reply-To", m.ReplyTo)
+	}
+	writeHeader(&msg, "To", m.To)
+	if len(m.Cc) > 0 {
+		writeHeader(&msg, "Cc", strings.Join(m.Cc, ", "))
+	}
+	writeHeader(&msg, "Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	writeHeader(&msg, "Date", time.Now().Format(time.RFC1123Z))
+	writeHeader(&msg, "Message-ID", messageID(m.From))
+	for _, h := range m.Headers {
+		writeHeader(&msg, h.Name, h.Value)
+	}
+	writeHeader(&msg, "MIME-Version", "1.0")
+
+	header, content, err := body.build()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+		if v := header.Get(name); v != "" {
+			writeHeader(&msg, name, v)
+		}
+	}
+	msg.WriteString("\r\n")
+	msg.Write(content)
+	return msg.Bytes(), nil
+}
+
+func writeHeader(w *bytes.Buffer, name, value string) {
+	w.WriteString(name + ": " + value + "\r\n")
+}
+
+// messageID is a unique id on the sender's domain.
+func messageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 && i < len(from)-1 {
+		domain = from[i+1:]
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(id), domain)
+}
+
+// entity is a MIME part, built into its headers and content.
+type entity interface {
+	build() (textproto.MIMEHeader, []byte, error)
+}
+
+type alternative struct {
+	text string
+	html []byte
+}
+
+func (a alternative) build() (textproto.MIMEHeader, []byte, error) {
+	return multipartEntity{subtype: "alternative", parts: []entity{
+		textEntity{contentType: "text/plain; charset=utf-8", content: []byte(a.text)},
+		textEntity{contentType: "text/html; charset=utf-8", content: a.html},
+	}}.build()
+}
+
+type textEntity struct {
+	contentType string
+	content     []byte
+}
+
+func (t textEntity) build() (textproto.MIMEHeader, []byte, error) {
+	var buf bytes.Buffer
+	w := quotedprintable.NewWriter(&buf)
+	if _, err := w.Write(t.content); err != nil {
+		return nil, nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, err
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", t.contentType)
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	return header, buf.Bytes(), nil
+}
+
+type attachmentEntity Attachment
+
+func attachmentEntities(attachments []Attachment) []entity {
+	parts := make([]entity, len(attachments))
+	for i, a := range attachments {
+		parts[i] = attachmentEntity(a)
+	}
+	return parts
+}
+
+func (a attachmentEntity) build() (textproto.MIMEHeader, []byte, error) {
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	disposition := "attachment"
+	header := textproto.MIMEHeader{}
+	if a.ContentID != "" {
+		disposition = "inline"
+		header.Set("Content-ID", "<"+a.ContentID+">")
+	}
+	if a.Filename != "" {
+		// The content type may carry parameters of its own, like a charset
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid content type of %s: %w", a.Filename, err)
+		}
+		params["name"] = a.Filename
+		contentType = mime.FormatMediaType(mediaType, params)
+		disposition = mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename})
+	}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", disposition)
+	header.Set("Content-Transfer-Encoding", "base64")
+
+	// Base64 lines are kept to 76 characters as RFC 2045 asks
+	encoded := base64.StdEncoding.EncodeToString(a.Data)
+	var buf bytes.Buffer
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	return header, buf.Bytes(), nil
+}
+
+type multipartEntity struct {
+	subtype string
+	parts   []entity
+}
+
+func (m multipartEntity) build() (textproto.MIMEHeader, []byte, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, part := range m.parts {
+		header, content, err := part.build()
+		if err != nil {
+			return nil, nil, err
+		}
+		pw, err := w.CreatePart(header)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := pw.Write(content); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, err
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType("multipart/"+m.subtype, map[string]string{"boundary": w.Boundary()}))
+	return header, buf.Bytes(), nil
+}
+
+var (
+	hiddenElements = regexp.MustCompile(`(?is)<(head|style|script)\b.*?</(head|style|script)>`)
+	links          = regexp.MustCompile(`(?is)<a\b[^>]*\bhref\s*=\s*["']([^"']*)["'][^>]*>(.*?)</a>`)
+	listItems      = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	lineBreaks     = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|h[1-6]|li|tr|ul|ol|table)>`)
+	tags           = regexp.MustCompile(`(?s)<[^>]*>`)
+	spaces         = regexp.MustCompile(`[ \t]+`)
+	blankLines     = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText is the plain text alternative of an HTML email, with links
+// written out after their text.
+func htmlToText(body string) string {
+	body = hiddenElements.ReplaceAllString(body, "")
+	body = links.ReplaceAllStringFunc(body, func(a string) string {
+		m := links.FindStringSubmatch(a)
+		text := strings.TrimSpace(tags.ReplaceAllString(m[2], ""))
+		href := strings.TrimSpace(m[1])
+		if href == "" || strings.HasPrefix(href, "cid:") || text == strings.TrimPrefix(href, "mailto:") {
+			return text
+		}
+		if text == "" {
+			return href
+		}
+		return text + " (" + href + ")"
+	})
+	body = listItems.ReplaceAllString(body, "\n- ")
+	body = lineBreaks.ReplaceAllString(body, "\n")
+	body = tags.ReplaceAllString(body, "")
+	body = html.UnescapeString(body)
+
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spaces.ReplaceAllString(line, " "))
+	}
+	body = strings.Join(lines, "\n")
+	body = blankLines.ReplaceAllString(body, "\n\n")
+	return strings.TrimSpace(body) + "\n"
+}
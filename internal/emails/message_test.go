@@ -0,0 +1,146 @@
+package email
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+type part struct {
+	header textproto.MIMEHeader
+	body   string
+}
+
+// parts reads the parts of a multipart entity, failing unless its media
+// type is the one expected.
+func parts(t *testing.T, contentType string, body io.Reader, want string) []part {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != want {
+		t.Fatalf("got %s, want %s (err %v)", mediaType, want, err)
+	}
+	var out []part
+	r := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, part{header: p.Header, body: string(data)})
+	}
+}
+
+func TestMessageHeaders(t *testing.T) {
+	m := &Message{
+		FromName: "E-Summit x E-Cell IIT Hyderabad",
+		From:     "esummit@ecell.in",
+		To:       "a@x.com",
+		Cc:       []string{"b@x.com"},
+		Subject:  "Your pass is ready ✔",
+		HTML:     []byte(`<p>Hello <b>Aarav</b>,</p><p>See <a href="https://x.in/s">the schedule</a></p>`),
+		Headers:  []Header{{Name: "List-Unsubscribe", Value: "<https://x.in/u>"}},
+	}
+	data, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != m.Subject {
+		t.Fatalf("subject %q, err %v", subject, err)
+	}
+	if !strings.HasPrefix(msg.Header.Get("Subject"), "=?utf-8?q?") {
+		t.Fatalf("subject not encoded: %q", msg.Header.Get("Subject"))
+	}
+	if _, err := msg.Header.Date(); err != nil {
+		t.Fatal(err)
+	}
+	if id := msg.Header.Get("Message-ID"); !strings.HasSuffix(id, "@ecell.in>") {
+		t.Fatalf("message id %q", id)
+	}
+	if msg.Header.Get("List-Unsubscribe") != "<https://x.in/u>" {
+		t.Fatal("extra header missing")
+	}
+
+	// Headers come out in a fixed order
+	head := string(data[:bytes.Index(data, []byte("\r\n\r\n"))])
+	var names []string
+	for _, line := range strings.Split(head, "\r\n") {
+		names = append(names, line[:strings.Index(line, ":")])
+	}
+	want := "From,To,Cc,Subject,Date,Message-ID,List-Unsubscribe,MIME-Version,Content-Type"
+	if strings.Join(names, ",") != want {
+		t.Fatalf("headers %v", names)
+	}
+
+	alt := parts(t, msg.Header.Get("Content-Type"), msg.Body, "multipart/alternative")
+	if len(alt) != 2 {
+		t.Fatalf("%d alternatives", len(alt))
+	}
+	text := alt[0].body
+	if !strings.Contains(text, "Hello Aarav,") || !strings.Contains(text, "the schedule (https://x.in/s)") {
+		t.Fatalf("text alternative %q", text)
+	}
+	if !strings.Contains(alt[1].body, "<b>Aarav</b>") {
+		t.Fatal("html alternative missing")
+	}
+}
+
+func TestMessageAttachments(t *testing.T) {
+	m := &Message{
+		From:    "esummit@ecell.in",
+		To:      "a@x.com",
+		Subject: "Pass",
+		HTML:    []byte(`<img src="cid:qrcode.png">`),
+		Attachments: []Attachment{
+			{Filename: "qrcode.png", ContentType: "image/png", ContentID: "qrcode.png", Data: []byte("png")},
+			{Filename: "esummit.ics", ContentType: "text/calendar; method=PUBLISH", Data: []byte("BEGIN:VCALENDAR")},
+		},
+	}
+	first, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := m.Bytes()
+
+	msg, err := mail.ReadMessage(bytes.NewReader(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := mail.ReadMessage(bytes.NewReader(second))
+	if msg.Header.Get("Content-Type") == other.Header.Get("Content-Type") {
+		t.Fatal("boundary reused between messages")
+	}
+
+	mixed := parts(t, msg.Header.Get("Content-Type"), msg.Body, "multipart/mixed")
+	if len(mixed) != 2 {
+		t.Fatalf("%d mixed parts", len(mixed))
+	}
+	if d := mixed[1].header.Get("Content-Disposition"); d != `attachment; filename=esummit.ics` {
+		t.Fatalf("disposition %q", d)
+	}
+	if ct := mixed[1].header.Get("Content-Type"); ct != `text/calendar; method=PUBLISH; name=esummit.ics` {
+		t.Fatalf("content type %q", ct)
+	}
+	related := parts(t, mixed[0].header.Get("Content-Type"), strings.NewReader(mixed[0].body), "multipart/related")
+	if len(related) != 2 || related[1].header.Get("Content-ID") != "<qrcode.png>" {
+		t.Fatalf("related parts %v", related)
+	}
+	parts(t, related[0].header.Get("Content-Type"), strings.NewReader(related[0].body), "multipart/alternative")
+}
@@ -0,0 +1,255 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/textproto"
+	"os"
+	"reg/internal/config"
+	"reg/internal/database"
+	"reg/internal/model"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often idle workers look for emails whose retry is due
+const pollInterval = 5 * time.Second
+
+// Delay before the first retry, doubled on every further attempt
+const (
+	retryBase = 30 * time.Second
+	retryMax  = time.Hour
+)
+
+// wake tells an idle worker that an email was queued
+var wake = make(chan struct{}, 1)
+
+// Workers take turns writing to the outbox, SQLite allows one writer at a
+// time and would fail the others as busy. Only sending is concurrent.
+var outboxMu sync.Mutex
+
+// enqueue stores a built message in the outbox and wakes a worker to send
+// it.
+func enqueue(e model.OutboxEmail) (int64, error) {
+	return enqueueWith(e, database.EnqueueEmail)
+}
+
+// enqueueWith is enqueue with store writing the outbox row, for emails that
+// are stored together with other changes.
+func enqueueWith(e model.OutboxEmail, store func(context.Context, model.OutboxEmail) (int64, error)) (int64, error) {
+	id, err := store(context.Background(), e)
+	if err != nil {
+		log.Printf("Failed to queue email: %v\n", err)
+		config.LogEmails(e.To, e.Recipients[1:], e.Subject, false)
+		return 0, err
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+	return id, nil
+}
+
+// workers is the number of emails delivered at once, set by EMAIL_WORKERS.
+func workers() int {
+	if n, err := strconv.Atoi(os.Getenv("EMAIL_WORKERS")); err == nil && n > 0 {
+		return n
+	}
+	return 4
+}
+
+// maxAttempts is how many times an email is tried before it is moved to the
+// dead letters, set by EMAIL_MAX_ATTEMPTS.
+func maxAttempts() int {
+	if n, err := strconv.Atoi(os.Getenv("EMAIL_MAX_ATTEMPTS")); err == nil && n > 0 {
+		return n
+	}
+	return 6
+}
+
+// backoff is the wait after the given number of failed attempts.
+func backoff(attempts int) time.Duration {
+	delay := retryBase
+	for i := 1; i < attempts && delay < retryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMax)
+}
+
+// permanent reports whether the mail server rejected an email for good, so
+// retrying it cannot help.
+func permanent(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code >= 500
+}
+
+// StartOutbox puts back emails a previous process was sending and starts
+// the workers that deliver the outbox.
+func StartOutbox() {
+	if n, err := database.RecoverOutbox(context.Background()); err != nil {
+		log.Printf("Failed to recover outbox: %v", err)
+	} else if n > 0 {
+		log.Printf("Recovered %d emails from the outbox", n)
+	}
+
+	for range workers() {
+		go work()
+	}
+}
+
+func work() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			outboxMu.Lock()
+			e, err := database.ClaimDueEmail(context.Background())
+			outboxMu.Unlock()
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			if e == nil {
+				break
+			}
+			deliver(e)
+		}
+
+		select {
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func deliver(e *model.OutboxEmail) {
+	ctx := context.Background()
+	cc := e.Recipients[1:]
+
+	err := send(e.From, e.Recipients, e.Message)
+
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+	if err == nil {
+		if err := database.MarkEmailSent(ctx, e.ID); err != nil {
+			fmt.Println(err)
+		}
+		config.LogEmails(e.To, cc, e.Subject, true)
+		recordPass(e, nil)
+		return
+	}
+
+	if permanent(err) || e.Attempts >= maxAttempts() {
+		log.Printf("Failed to send email %d to %s, giving up: %v\n", e.ID, e.To, err)
+		if err := database.MarkEmailFailed(ctx, e.ID, err, nil); err != nil {
+			fmt.Println(err)
+		}
+		config.LogEmails(e.To, cc, e.Subject, false)
+		recordPass(e, err)
+		return
+	}
+
+	retryAt := time.Now().Add(backoff(e.Attempts))
+	log.Printf("Failed to send email %d to %s, retrying at %s: %v\n", e.ID, e.To, retryAt.Format(time.RFC3339), err)
+	if err := database.MarkEmailFailed(ctx, e.ID, err, &retryAt); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// recordPass stores the delivery of a pass email against its ticket.
+func recordPass(e *model.OutboxEmail, sendErr error) {
+	if e.TicketID == nil {
+		return
+	}
+	if err := database.RecordPassDelivery(context.Background(), *e.TicketID, sendErr); err != nil {
+		fmt.Println(err)
+	}
+}
+
+var (
+	// ErrPassNotLinked is returned when resending a pass email that is not
+	// linked to a ticket, its pass has to be sent again with a dispatch job.
+	ErrPassNotLinked = errors.New("pass email is not linked to a ticket")
+	// ErrPassNotActive is returned when resending the pass of a ticket that
+	// was cancelled or has no pass code.
+	ErrPassNotActive = errors.New("ticket is not active")
+)
+
+// Resend queues a copy of an email the outbox has delivered or given up on,
+// with a new date and Message-ID so mail clients do not take it for the
+// copy they already have. It returns the outbox id of the copy.
+//
+// Pass emails are built again from their ticket instead, so the copy goes
+// to the current owner with the current pass code.
+func Resend(id int) (int64, error) {
+	e, err := database.GetOutboxEmail(context.Background(), id)
+	if err != nil {
+		return 0, err
+	}
+	if e.Status == database.OutboxPending || e.Status == database.OutboxSending {
+		return 0, database.ErrOutboxEmailPending
+	}
+	if e.Template == "pass" {
+		return resendPass(e)
+	}
+
+	e.Message = restamp(e.Message, e.From)
+	e.ResentFrom = &e.ID
+	return enqueue(*e)
+}
+
+// resendPass queues the pass of the ticket a pass email was sent for.
+func resendPass(e *model.OutboxEmail) (int64, error) {
+	if e.TicketID == nil {
+		return 0, ErrPassNotLinked
+	}
+	ticket, err := database.GetUserTicket(context.Background(), *e.TicketID)
+	if err != nil {
+		return 0, err
+	}
+	if ticket.Status != "active" || ticket.UID == "" {
+		return 0, ErrPassNotActive
+	}
+
+	room, err := database.GetTicketAllocation(context.Background(), ticket.TicketID)
+	if err != nil && !errors.Is(err, database.ErrAllocationNotFound) {
+		return 0, err
+	}
+	m, err := ticketPassMessage(*ticket, room)
+	if err != nil {
+		return 0, err
+	}
+	pass, err := outboxEmail(m, &ticket.TicketID)
+	if err != nil {
+		return 0, err
+	}
+	pass.ResentFrom = &e.ID
+	return enqueue(pass)
+}
+
+// restamp replaces the Date and Message-ID headers of a built message.
+func restamp(message []byte, from string) []byte {
+	end := bytes.Index(message, []byte("\r\n\r\n"))
+	if end < 0 {
+		return message
+	}
+
+	lines := strings.Split(string(message[:end]), "\r\n")
+	for i, line := range lines {
+		name, _, _ := strings.Cut(line, ":")
+		switch strings.ToLower(name) {
+		case "date":
+			lines[i] = "Date: " + time.Now().Format(time.RFC1123Z)
+		case "message-id":
+			lines[i] = "Message-ID: " + messageID(from)
+		}
+	}
+	return append([]byte(strings.Join(lines, "\r\n")), message[end:]...)
+}
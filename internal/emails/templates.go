@@ -0,0 +1,182 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"reg/internal/model"
+	"reg/templates"
+	"strings"
+	"sync"
+)
+
+type OTPData struct {
+	OTP string
+}
+
+type SignUpData struct {
+	Name string
+}
+
+type TicketPurchasedData struct {
+	Name       string
+	TicketType string
+	Price      string
+}
+
+type PendingData struct {
+	Name          string
+	TransactionID string
+	Amount        string
+}
+
+type WaitlistData struct {
+	Name       string
+	TicketType string
+	ExpiresAt  string
+}
+
+type TransferOfferData struct {
+	Name       string
+	FromName   string
+	TicketType string
+	AcceptURL  string
+	ExpiresAt  string
+}
+
+type TransferCompleteData struct {
+	Name       string
+	ToName     string
+	TicketType string
+}
+
+type PassData struct {
+	Name            string
+	Pass            string
+	Code            string
+	AppleWalletURL  string
+	GoogleWalletURL string
+	Accommodation   *model.RoomAllocation
+}
+
+// CampaignData fills the templates campaigns can be sent with.
+type CampaignData struct {
+	Name           string
+	Heading        string
+	Paragraphs     []string
+	ActionText     string
+	ActionURL      string
+	UnsubscribeURL string
+}
+
+// UnsubscribeData fills the page an unsubscribe link opens.
+type UnsubscribeData struct {
+	Email    string
+	Category string
+	Token    string
+	Done     bool
+}
+
+// CampaignTemplates are the templates an admin can pick for a campaign.
+var CampaignTemplates = []string{"announcement", "buy_now"}
+
+// templateData is the type each template is executed with. Every template
+// is tried with its zero value at startup, so a missing template or a field
+// the type does not have stops the server instead of a send.
+var templateData = map[string]any{
+	"otp.html":               OTPData{},
+	"signup.html":            SignUpData{},
+	"register.html":          model.RegistrationRequest{},
+	"tickets_purchased.html": TicketPurchasedData{},
+	"pending.html":           PendingData{},
+	"waitlist.html":          WaitlistData{},
+	"transfer_offer.html":    TransferOfferData{},
+	"transfer_complete.html": TransferCompleteData{},
+	"pass.html":              PassData{},
+	"announcement.html":      CampaignData{},
+	"buy_now.html":           CampaignData{},
+	"unsubscribe.html":       UnsubscribeData{},
+}
+
+var (
+	templatesOnce sync.Once
+	registry      *template.Template
+	templatesErr  error
+)
+
+// InitTemplates parses the embedded email templates, replacing any with a
+// file of the same name in EMAIL_TEMPLATE_DIR, and checks each against its
+// data type. It only does the work once.
+func InitTemplates() error {
+	templatesOnce.Do(func() {
+		registry, templatesErr = parseTemplates(os.Getenv("EMAIL_TEMPLATE_DIR"))
+	})
+	return templatesErr
+}
+
+func parseTemplates(overrideDir string) (*template.Template, error) {
+	set, err := template.New("").ParseFS(templates.FS, "*.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email templates: %w", err)
+	}
+
+	if overrideDir != "" {
+		files, err := filepath.Glob(filepath.Join(overrideDir, "*.html"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list email templates in %s: %w", overrideDir, err)
+		}
+		if len(files) > 0 {
+			if set, err = set.ParseFiles(files...); err != nil {
+				return nil, fmt.Errorf("failed to parse email templates in %s: %w", overrideDir, err)
+			}
+		}
+	}
+
+	for name, data := range templateData {
+		if set.Lookup(name) == nil {
+			return nil, fmt.Errorf("email template %s is missing", name)
+		}
+		if err := set.ExecuteTemplate(io.Discard, name, data); err != nil {
+			return nil, fmt.Errorf("email template %s is broken: %w", name, err)
+		}
+	}
+	return set, nil
+}
+
+// Body is the HTML of an email, the template it was rendered from, which
+// the outbox records, and any files that go with it.
+type Body struct {
+	Template    string
+	HTML        []byte
+	Attachments []Attachment
+}
+
+// renderBody renders an email from a template of the registry.
+func renderBody(name string, data any) (Body, error) {
+	html, err := render(name, data)
+	if err != nil {
+		return Body{}, err
+	}
+	return Body{Template: strings.TrimSuffix(name, ".html"), HTML: html}, nil
+}
+
+// render executes a template of the registry.
+func render(name string, data any) ([]byte, error) {
+	if err := InitTemplates(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := registry.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderUnsubscribePage renders the page that confirms an unsubscribe.
+func RenderUnsubscribePage(data UnsubscribeData) ([]byte, error) {
+	return render("unsubscribe.html", data)
+}
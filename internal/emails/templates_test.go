@@ -0,0 +1,48 @@
+package email
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplatesEscapeData(t *testing.T) {
+	body, err := LoadSignUpVerificationTemplate(`<script>alert(1)</script>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body.HTML), "<script>") || !strings.Contains(string(body.HTML), "&lt;script&gt;") {
+		t.Fatal("name was not escaped")
+	}
+
+	body, err = LoadOtpVerificationsTemplate("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body.HTML), `<div class="otp-box">123456</div>`) {
+		t.Fatal("otp missing from email")
+	}
+}
+
+func TestTemplateOverrides(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "otp.html"), []byte("Code: {{.OTP}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	set, err := parseTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := set.ExecuteTemplate(&out, "otp.html", OTPData{OTP: "42"}); err != nil || out.String() != "Code: 42" {
+		t.Fatalf("got %q, err %v", out.String(), err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "otp.html"), []byte("Code: {{.Code}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseTemplates(dir); err == nil {
+		t.Fatal("template with an unknown field was accepted")
+	}
+}
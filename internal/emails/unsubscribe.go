@@ -0,0 +1,92 @@
+package email
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reg/internal/config"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe token")
+
+var ErrUnsubscribeUnavailable = errors.New("unsubscribe links cannot be built: PUBLIC_API_URL or a signing key is not configured")
+
+// unsubscribeSecret is the key unsubscribe links are signed with,
+// UNSUBSCRIBE_SECRET or a key derived from SECRET_KEY.
+func unsubscribeSecret() ([]byte, error) {
+	return config.SigningKey("UNSUBSCRIBE_SECRET", "unsubscribe")
+}
+
+func signUnsubscribe(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("unsubscribe." + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))[:22]
+}
+
+// UnsubscribeToken authorises turning off one category of email for a user,
+// formatted as <user id>.<category>.<signature>. It does not expire, so links
+// in old emails keep working.
+func UnsubscribeToken(userID int, category string) (string, error) {
+	key, err := unsubscribeSecret()
+	if err != nil {
+		return "", err
+	}
+	payload := fmt.Sprintf("%d.%s", userID, category)
+	return payload + "." + signUnsubscribe(key, payload), nil
+}
+
+// ParseUnsubscribeToken checks the signature of an unsubscribe token and
+// returns the user and category it was issued for.
+func ParseUnsubscribeToken(token string) (int, string, error) {
+	key, err := unsubscribeSecret()
+	if err != nil {
+		return 0, "", err
+	}
+
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return 0, "", ErrInvalidUnsubscribeToken
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(signUnsubscribe(key, payload))) {
+		return 0, "", ErrInvalidUnsubscribeToken
+	}
+
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil || userID <= 0 {
+		return 0, "", ErrInvalidUnsubscribeToken
+	}
+	return userID, parts[1], nil
+}
+
+// UnsubscribeURL is the public one-click unsubscribe link for a user and
+// category, empty when PUBLIC_API_URL or the secret is not configured.
+func UnsubscribeURL(userID int, category string) string {
+	base := strings.TrimRight(os.Getenv("PUBLIC_API_URL"), "/")
+	if base == "" {
+		return ""
+	}
+	token, err := UnsubscribeToken(userID, category)
+	if err != nil {
+		return ""
+	}
+	return base + "/unsubscribe?token=" + url.QueryEscape(token)
+}
+
+// CheckUnsubscribe returns ErrUnsubscribeUnavailable when unsubscribe links
+// cannot be built, campaign mail must not go out without one.
+func CheckUnsubscribe() error {
+	if os.Getenv("PUBLIC_API_URL") == "" {
+		return ErrUnsubscribeUnavailable
+	}
+	if _, err := unsubscribeSecret(); err != nil {
+		return ErrUnsubscribeUnavailable
+	}
+	return nil
+}
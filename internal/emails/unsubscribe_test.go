@@ -0,0 +1,67 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnsubscribeTokenRoundTrip(t *testing.T) {
+	t.Setenv("UNSUBSCRIBE_SECRET", "test-secret")
+
+	token, err := UnsubscribeToken(42, "announcements")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, category, err := ParseUnsubscribeToken(token)
+	if err != nil {
+		t.Fatalf("ParseUnsubscribeToken(%q) returned error: %v", token, err)
+	}
+	if id != 42 || category != "announcements" {
+		t.Errorf("ParseUnsubscribeToken(%q) = %d, %q, want 42, announcements", token, id, category)
+	}
+}
+
+func TestParseUnsubscribeTokenRejectsTamperedTokens(t *testing.T) {
+	t.Setenv("UNSUBSCRIBE_SECRET", "test-secret")
+
+	token, err := UnsubscribeToken(7, "reminders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+
+	tampered := []string{
+		"",
+		strings.Join([]string{"8", parts[1], parts[2]}, "."),
+		strings.Join([]string{parts[0], "announcements", parts[2]}, "."),
+		strings.Join([]string{parts[0], parts[1], "AAAAAAAAAAAAAAAAAAAAAA"}, "."),
+		token + ".extra",
+	}
+	for _, tok := range tampered {
+		if _, _, err := ParseUnsubscribeToken(tok); err != ErrInvalidUnsubscribeToken {
+			t.Errorf("ParseUnsubscribeToken(%q) error = %v, want ErrInvalidUnsubscribeToken", tok, err)
+		}
+	}
+}
+
+func TestUnsubscribeURL(t *testing.T) {
+	t.Setenv("UNSUBSCRIBE_SECRET", "test-secret")
+
+	t.Setenv("PUBLIC_API_URL", "")
+	if got := UnsubscribeURL(1, "announcements"); got != "" {
+		t.Errorf("UnsubscribeURL without PUBLIC_API_URL = %q, want empty", got)
+	}
+	if err := CheckUnsubscribe(); err != ErrUnsubscribeUnavailable {
+		t.Errorf("CheckUnsubscribe without PUBLIC_API_URL = %v, want ErrUnsubscribeUnavailable", err)
+	}
+
+	t.Setenv("PUBLIC_API_URL", "https://api.example.com/")
+	got := UnsubscribeURL(1, "announcements")
+	if !strings.HasPrefix(got, "https://api.example.com/unsubscribe?token=1.announcements.") {
+		t.Errorf("UnsubscribeURL = %q", got)
+	}
+	if err := CheckUnsubscribe(); err != nil {
+		t.Errorf("CheckUnsubscribe = %v", err)
+	}
+}
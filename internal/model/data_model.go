@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type RegistrationData struct {
 	Id       *int   `json:"id"`
 	SName    string `json:"sname"`
@@ -28,6 +30,7 @@ type User struct {
 	Name          string `json:"name"`
 	ContactNumber string `json:"contact_number"`
 	Data          string `json:"data"`
+	Gender        string `json:"gender"`
 }
 
 type PurchasedTicketWithUser struct {
@@ -42,8 +45,357 @@ type PurchasedTicketWithUser struct {
 
 type UserTicket struct {
 	ID          int
+	TicketID    int
 	Name        string
 	Email       string
 	TicketTitle string
 	UID         string //unique id
+	Status      string
+}
+
+// Ticket is an entry of the ticket catalog
+type Ticket struct {
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	Capacity    int     `json:"capacity"` // -1 when unlimited
+}
+
+type PurchasedTicket struct {
+	ID              int     `json:"id"`
+	UserID          int     `json:"user_id"`
+	TicketTitle     string  `json:"ticket_title"`
+	Price           float64 `json:"price"`
+	IsAccommodation bool    `json:"is_accommodation"`
+	Coupon          string  `json:"coupon"`
+	CreatedAt       string  `json:"created_at"`
+}
+
+type GroupMember struct {
+	Email         string `json:"email"`
+	Name          string `json:"name"`
+	ContactNumber string `json:"contact_number"`
+	TicketID      *int   `json:"ticket_id"`
+}
+
+type GroupOrder struct {
+	ID              int           `json:"id"`
+	TxnID           string        `json:"txn_id"`
+	TicketTitle     string        `json:"ticket_title"`
+	Amount          float64       `json:"amount"`
+	IsAccommodation bool          `json:"is_accommodation"`
+	IsVerified      bool          `json:"is_verified"`
+	CreatedAt       string        `json:"created_at"`
+	Members         []GroupMember `json:"members"`
+}
+
+type Inventory struct {
+	Title     string `json:"title"`
+	Capacity  int    `json:"capacity"`
+	Sold      int    `json:"sold"`
+	Held      int    `json:"held"`
+	Available int    `json:"available"` // -1 when unlimited
+	Waiting   int    `json:"waiting"`
+}
+
+type WaitlistEntry struct {
+	ID          int    `json:"id"`
+	UserID      int    `json:"user_id"`
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	TicketTitle string `json:"ticket_title"`
+	Status      string `json:"status"`
+	Position    int    `json:"position,omitempty"`
+	CreatedAt   string `json:"created_at"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+}
+
+type PricingPhase struct {
+	ID          int     `json:"id"`
+	TicketTitle string  `json:"ticket_title"`
+	Name        string  `json:"name"`
+	Price       float64 `json:"price"`
+	StartsAt    *string `json:"starts_at"`
+	EndsAt      *string `json:"ends_at"`
+	MaxQuantity *int    `json:"max_quantity"`
+	Sold        int     `json:"sold"`
+}
+
+// PriceQuote is the price of a tier right now, Phase is empty when the
+// catalog price applies. BasePrice is the catalog price of the tier and
+// Accommodation the part of Price paid for the accommodation add-on.
+type PriceQuote struct {
+	Title         string  `json:"title"`
+	Phase         string  `json:"phase"`
+	Price         float64 `json:"price"`
+	BasePrice     float64 `json:"base_price"`
+	Accommodation float64 `json:"accommodation,omitempty"`
+}
+
+// Report is a tabular admin report, every row holds one value per column.
+type Report struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+type Scanner struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Gate      string `json:"gate"`
+	Zone      string `json:"zone"`
+	IsActive  bool   `json:"is_active"`
+	CreatedBy string `json:"created_by"`
+	CreatedAt string `json:"created_at"`
+	TokenHash string `json:"-"`
+}
+
+type CheckIn struct {
+	Result       string   `json:"result"`
+	Allowed      bool     `json:"allowed"`
+	TicketID     int      `json:"ticket_id,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	Email        string   `json:"email,omitempty"`
+	TicketTitle  string   `json:"ticket_title,omitempty"`
+	Gate         string   `json:"gate"`
+	Zone         string   `json:"zone,omitempty"`
+	CheckedInAt  string   `json:"checked_in_at"`
+	FirstGate    string   `json:"first_gate,omitempty"`
+	FirstCheckIn string   `json:"first_checked_in_at,omitempty"`
+	Entitlements []string `json:"entitlements,omitempty"`
+}
+
+type Entitlement struct {
+	TicketTitle string `json:"ticket_title"`
+	Kind        string `json:"kind"`
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	MaxUses     *int   `json:"max_uses"`
+}
+
+// ScheduleEvent is an event day, or the slot of the entitlement with the
+// same code.
+type ScheduleEvent struct {
+	Code        string    `json:"code"`
+	Kind        string    `json:"kind"`
+	Summary     string    `json:"summary"`
+	Description string    `json:"description"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+}
+
+type OfflineScan struct {
+	ClientID   string    `json:"client_id"`
+	Code       string    `json:"code"`
+	DeviceTime time.Time `json:"device_time"`
+}
+
+type SyncResult struct {
+	ClientID  string   `json:"client_id"`
+	Duplicate bool     `json:"duplicate"`
+	Conflict  bool     `json:"conflict"`
+	CheckIn   *CheckIn `json:"checkin"`
+}
+
+// ManifestPass is one pass in a scanner manifest. Passes are identified by
+// a hash of their code so the manifest does not hand out usable codes.
+type ManifestPass struct {
+	Hash          string `json:"h"`
+	TicketID      int    `json:"id"`
+	Name          string `json:"n"`
+	TicketTitle   string `json:"t"`
+	Accommodation bool   `json:"a,omitempty"`
+	MaxUses       int    `json:"m"`
+	Used          int    `json:"u"`
+}
+
+type ScannerManifest struct {
+	GeneratedAt  string              `json:"generated_at"`
+	ScannerID    int                 `json:"scanner_id"`
+	Gate         string              `json:"gate"`
+	Zone         string              `json:"zone"`
+	HashFunction string              `json:"hash"`
+	Entitlements map[string][]string `json:"entitlements"`
+	Passes       []ManifestPass      `json:"passes"`
+}
+
+type TierCheckIns struct {
+	CheckedIn int `json:"checked_in"`
+	Issued    int `json:"issued"`
+}
+
+type CheckInCounts struct {
+	CheckedIn int                     `json:"checked_in"`
+	Issued    int                     `json:"issued"`
+	ByGate    map[string]int          `json:"by_gate"`
+	ByZone    map[string]int          `json:"by_zone"`
+	ByTier    map[string]TierCheckIns `json:"by_tier"`
+}
+
+type CheckInSnapshot struct {
+	GeneratedAt string        `json:"generated_at"`
+	Counts      CheckInCounts `json:"counts"`
+	Recent      []CheckIn     `json:"recent"`
+}
+
+type DispatchJob struct {
+	ID         int     `json:"id"`
+	StartedBy  string  `json:"started_by"`
+	Status     string  `json:"status"`
+	Total      int     `json:"total"`
+	Sent       int     `json:"sent"`
+	Failed     int     `json:"failed"`
+	StartedAt  string  `json:"started_at"`
+	FinishedAt *string `json:"finished_at"`
+}
+
+type PassTransfer struct {
+	ID          int     `json:"id"`
+	TicketID    int     `json:"ticket_id"`
+	TicketTitle string  `json:"ticket_title"`
+	FromName    string  `json:"from_name"`
+	FromEmail   string  `json:"from_email"`
+	ToName      string  `json:"to_name"`
+	ToEmail     string  `json:"to_email"`
+	Status      string  `json:"status"`
+	CreatedAt   string  `json:"created_at"`
+	ExpiresAt   string  `json:"expires_at"`
+	ResolvedAt  *string `json:"resolved_at"`
+}
+
+type Room struct {
+	ID       int    `json:"id"`
+	HostelID int    `json:"hostel_id"`
+	Number   string `json:"number"`
+	Capacity int    `json:"capacity"`
+	Occupied int    `json:"occupied"`
+}
+
+type Hostel struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Gender   string `json:"gender"`
+	Capacity int    `json:"capacity"`
+	Occupied int    `json:"occupied"`
+	Rooms    []Room `json:"rooms"`
+}
+
+type RoomAllocation struct {
+	TicketID     int     `json:"ticket_id"`
+	Name         string  `json:"name"`
+	Email        string  `json:"email"`
+	Gender       string  `json:"gender"`
+	HostelID     int     `json:"hostel_id"`
+	Hostel       string  `json:"hostel"`
+	RoomID       int     `json:"room_id"`
+	Room         string  `json:"room"`
+	Status       string  `json:"status"`
+	AllocatedBy  string  `json:"allocated_by"`
+	AllocatedAt  string  `json:"allocated_at"`
+	CheckedInAt  *string `json:"checked_in_at"`
+	CheckedOutAt *string `json:"checked_out_at"`
+}
+
+type UnallocatedTicket struct {
+	TicketID int    `json:"ticket_id"`
+	Email    string `json:"email"`
+	Gender   string `json:"gender"`
+	Reason   string `json:"reason"`
+}
+
+type OutboxEmail struct {
+	ID            int      `json:"id"`
+	From          string   `json:"from"`
+	Recipients    []string `json:"recipients"`
+	To            string   `json:"to"`
+	Subject       string   `json:"subject"`
+	Message       []byte   `json:"-"`
+	TicketID      *int     `json:"ticket_id"`
+	UserID        *int     `json:"user_id"`
+	Template      string   `json:"template"`
+	ResentFrom    *int     `json:"resent_from"`
+	Status        string   `json:"status"`
+	Attempts      int      `json:"attempts"`
+	LastError     string   `json:"last_error"`
+	NextAttemptAt *string  `json:"next_attempt_at"`
+	CreatedAt     string   `json:"created_at"`
+	SentAt        *string  `json:"sent_at"`
+}
+
+// OutboxFilter narrows a search of the outbox. Empty fields match every
+// email, Since and Until are UTC times in the database's format.
+type OutboxFilter struct {
+	Recipient string
+	Template  string
+	Status    string
+	UserID    int
+	Since     string
+	Until     string
+	Limit     int
+	Offset    int
+}
+
+// CampaignSegment picks the users a campaign is sent to. Empty fields match
+// everyone.
+type CampaignSegment struct {
+	Tiers     []string `json:"tiers,omitempty"`
+	Purchased *bool    `json:"purchased,omitempty"`
+	CheckedIn *bool    `json:"checked_in,omitempty"`
+	Colleges  []string `json:"colleges,omitempty"`
+}
+
+type Campaign struct {
+	ID            int             `json:"id"`
+	Name          string          `json:"name"`
+	Template      string          `json:"template"`
+	Subject       string          `json:"subject"`
+	Heading       string          `json:"heading"`
+	Message       string          `json:"message"`
+	ActionText    string          `json:"action_text"`
+	ActionURL     string          `json:"action_url"`
+	Segment       CampaignSegment `json:"segment"`
+	Category      string          `json:"category"`
+	RatePerMinute int             `json:"rate_per_minute"`
+	Status        string          `json:"status"`
+	CreatedBy     string          `json:"created_by"`
+	CreatedAt     string          `json:"created_at"`
+	StartedAt     *string         `json:"started_at"`
+	FinishedAt    *string         `json:"finished_at"`
+}
+
+type CampaignRecipient struct {
+	UserID   int     `json:"user_id"`
+	Name     string  `json:"name"`
+	Email    string  `json:"email"`
+	Status   string  `json:"status"`
+	Error    string  `json:"error"`
+	OutboxID *int    `json:"outbox_id"`
+	QueuedAt *string `json:"queued_at"`
+}
+
+// EmailPreferences are the categories of email a user receives.
+// Transactional emails cannot be turned off.
+type EmailPreferences struct {
+	Transactional bool `json:"transactional"`
+	Announcements bool `json:"announcements"`
+	Reminders     bool `json:"reminders"`
+}
+
+// Bounce is a recipient a delivery status notification reports as failed.
+type Bounce struct {
+	Email      string `json:"email"`
+	Status     string `json:"status"`
+	Diagnostic string `json:"diagnostic"`
+}
+
+// UndeliverableUser is a user whose email address bounced, for the help
+// desk to reach by phone.
+type UndeliverableUser struct {
+	UserID        int    `json:"user_id"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	ContactNumber string `json:"contact_number"`
+	BouncedAt     string `json:"bounced_at"`
+	Reason        string `json:"reason"`
+	Bounces       int    `json:"bounces"`
 }
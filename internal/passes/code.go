@@ -0,0 +1,74 @@
+package passes
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reg/internal/config"
+	"strconv"
+	"strings"
+)
+
+const codePrefix = "ES"
+
+var ErrInvalidCode = errors.New("invalid pass code")
+
+// secret is the key pass codes are signed with, PASS_SECRET or a key derived
+// from SECRET_KEY.
+func secret() ([]byte, error) {
+	return config.SigningKey("PASS_SECRET", "pass-code")
+}
+
+func sign(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))[:22]
+}
+
+// NewCode returns a fresh pass code for a ticket, formatted as
+// ES.<ticket id>.<nonce>.<signature>. The random nonce makes every code
+// issued for the same ticket different.
+func NewCode(ticketID int) (string, error) {
+	key, err := secret()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, 4)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	payload := fmt.Sprintf("%s.%d.%s", codePrefix, ticketID, hex.EncodeToString(nonce))
+	return payload + "." + sign(key, payload), nil
+}
+
+// Verify checks the signature of a pass code and returns the ticket id it
+// was issued for. It does not know whether the code is still valid, callers
+// have to check it against the stored code.
+func Verify(code string) (int, error) {
+	key, err := secret()
+	if err != nil {
+		return 0, err
+	}
+
+	parts := strings.Split(strings.TrimSpace(code), ".")
+	if len(parts) != 4 || parts[0] != codePrefix {
+		return 0, ErrInvalidCode
+	}
+
+	payload := strings.Join(parts[:3], ".")
+	if !hmac.Equal([]byte(parts[3]), []byte(sign(key, payload))) {
+		return 0, ErrInvalidCode
+	}
+
+	ticketID, err := strconv.Atoi(parts[1])
+	if err != nil || ticketID <= 0 {
+		return 0, ErrInvalidCode
+	}
+	return ticketID, nil
+}
@@ -0,0 +1,60 @@
+package passes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCodeRoundTrip(t *testing.T) {
+	t.Setenv("PASS_SECRET", "test-secret")
+
+	code, err := NewCode(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := Verify(code)
+	if err != nil {
+		t.Fatalf("Verify(%q) returned error: %v", code, err)
+	}
+	if id != 42 {
+		t.Errorf("Verify(%q) = %d, want 42", code, id)
+	}
+
+	other, err := NewCode(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == code {
+		t.Errorf("NewCode returned the same code twice: %q", code)
+	}
+}
+
+func TestVerifyRejectsTamperedCodes(t *testing.T) {
+	t.Setenv("PASS_SECRET", "test-secret")
+
+	code, err := NewCode(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(code, ".")
+
+	tampered := []string{
+		"",
+		"7_PREMIUM_someone@example.com_GUEST",
+		strings.Join([]string{parts[0], "8", parts[2], parts[3]}, "."),
+		strings.Join([]string{parts[0], parts[1], "00000000", parts[3]}, "."),
+		strings.Join([]string{parts[0], parts[1], parts[2], "AAAAAAAAAAAAAAAAAAAAAA"}, "."),
+		code + ".extra",
+	}
+	for _, c := range tampered {
+		if _, err := Verify(c); err != ErrInvalidCode {
+			t.Errorf("Verify(%q) error = %v, want ErrInvalidCode", c, err)
+		}
+	}
+
+	t.Setenv("PASS_SECRET", "another-secret")
+	if _, err := Verify(code); err != ErrInvalidCode {
+		t.Errorf("Verify with a different secret error = %v, want ErrInvalidCode", err)
+	}
+}
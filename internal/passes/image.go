@@ -0,0 +1,131 @@
+package passes
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+)
+
+const (
+	SymbologyQR      = "qr"
+	SymbologyCode128 = "code128"
+)
+
+// Symbology returns the barcode type passes are rendered with, set by
+// PASS_SYMBOLOGY. QR is the default as it scans far better from phones.
+func Symbology() string {
+	switch strings.ToLower(os.Getenv("PASS_SYMBOLOGY")) {
+	case SymbologyCode128:
+		return SymbologyCode128
+	default:
+		return SymbologyQR
+	}
+}
+
+// encode returns the unscaled barcode for a pass code and the quiet zone,
+// in modules, to leave around it.
+func encode(code string) (barcode.Barcode, int, error) {
+	if Symbology() == SymbologyCode128 {
+		bc, err := code128.Encode(code)
+		return bc, 10, err
+	}
+	bc, err := qr.Encode(code, qr.M, qr.Auto)
+	return bc, 4, err
+}
+
+// PNG renders a pass code as a PNG image.
+func PNG(code string) ([]byte, error) {
+	bc, quiet, err := encode(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode pass: %w", err)
+	}
+
+	// Scale to whole pixels per module so the bars stay sharp
+	bounds := bc.Bounds()
+	var img image.Image
+	if bc.Metadata().Dimensions == 1 {
+		module := 1024 / (bounds.Dx() + 2*quiet)
+		if module < 1 {
+			module = 1
+		}
+		scaled, err := barcode.Scale(bc, bounds.Dx()*module, 200)
+		if err != nil {
+			return nil, err
+		}
+		img = withMargin(scaled, quiet*module, 0)
+	} else {
+		module := 512 / (bounds.Dx() + 2*quiet)
+		if module < 1 {
+			module = 1
+		}
+		scaled, err := barcode.Scale(bc, bounds.Dx()*module, bounds.Dy()*module)
+		if err != nil {
+			return nil, err
+		}
+		img = withMargin(scaled, quiet*module, quiet*module)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func withMargin(img image.Image, x, y int) image.Image {
+	b := img.Bounds()
+	out := image.NewGray(image.Rect(0, 0, b.Dx()+2*x, b.Dy()+2*y))
+	draw.Draw(out, out.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(out, image.Rect(x, y, x+b.Dx(), y+b.Dy()), img, b.Min, draw.Src)
+	return out
+}
+
+// SVG renders a pass code as an SVG image, one unit per module.
+func SVG(code string) ([]byte, error) {
+	bc, quiet, err := encode(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode pass: %w", err)
+	}
+
+	bounds := bc.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	barHeight := 1
+	if bc.Metadata().Dimensions == 1 {
+		// Linear codes are one module high, give the bars a readable height
+		height, barHeight = 1, 20
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, width+2*quiet, height*barHeight+2*quiet)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="#fff"/><path fill="#000" d="`)
+	for y := 0; y < height; y++ {
+		// Merge runs of dark modules into one rectangle each
+		for x := 0; x < width; {
+			if !isDark(bc.At(bounds.Min.X+x, bounds.Min.Y+y)) {
+				x++
+				continue
+			}
+			start := x
+			for x < width && isDark(bc.At(bounds.Min.X+x, bounds.Min.Y+y)) {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv%dh-%dz", start+quiet, y*barHeight+quiet, x-start, barHeight, x-start)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes(), nil
+}
+
+func isDark(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r+g+b < 3*0x8000
+}
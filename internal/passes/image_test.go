@@ -0,0 +1,41 @@
+package passes
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestRenderSymbologies(t *testing.T) {
+	t.Setenv("PASS_SECRET", "test-secret")
+	code, err := NewCode(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, symbology := range []string{SymbologyQR, SymbologyCode128} {
+		t.Setenv("PASS_SYMBOLOGY", symbology)
+
+		data, err := PNG(code)
+		if err != nil {
+			t.Fatalf("%s: PNG: %v", symbology, err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: decode PNG: %v", symbology, err)
+		}
+		b := img.Bounds()
+		if symbology == SymbologyQR && b.Dx() != b.Dy() {
+			t.Errorf("qr image is %dx%d, want square", b.Dx(), b.Dy())
+		}
+
+		svg, err := SVG(code)
+		if err != nil {
+			t.Fatalf("%s: SVG: %v", symbology, err)
+		}
+		if !strings.HasPrefix(string(svg), "<svg") || !strings.HasSuffix(string(svg), "</svg>") {
+			t.Errorf("%s: malformed svg", symbology)
+		}
+	}
+}
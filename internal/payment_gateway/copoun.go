@@ -3,35 +3,25 @@ package paymentgateway
 import (
 	"net/http"
 	"os"
+	"reg/internal/model"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func HandleCouponVerifications(c *gin.Context) {
-	coupons := os.Getenv("COUPON_CODES")
+type CouponDetails struct {
+	Discount      int     // Discount amount
+	OriginalPrice float64 // Catalog price of the tier the coupon applies to
+}
 
-	type CouponDetails struct {
-		Discount      int     // Discount amount
-		OriginalPrice float64 // Original price the coupon applies to
-	}
+// parseCoupons reads the COUPON_CODES env variable, formatted as
+// CODE:DISCOUNT;ORIGINAL_PRICE entries separated by commas.
+func parseCoupons() (map[string]CouponDetails, bool) {
+	coupons := os.Getenv("COUPON_CODES")
 
 	couponMap := make(map[string]CouponDetails)
 
-	var requestBody struct {
-		Code          string  `json:"couponCode"`
-		OriginalPrice float64 `json:"originalPrice"`
-	}
-
-	if err := c.ShouldBindJSON(&requestBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
-		return
-	}
-
-	code := strings.TrimSpace(requestBody.Code)
-	requestOriginalPrice := requestBody.OriginalPrice
-
 	for _, coupon := range strings.Split(coupons, ",") {
 		coupon = strings.TrimSpace(coupon)
 		parts := strings.Split(coupon, ":")
@@ -53,25 +43,76 @@ func HandleCouponVerifications(c *gin.Context) {
 						OriginalPrice: originalPrice,
 					}
 				} else {
-					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon"})
-					return
+					return nil, false
 				}
 			} else {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon"})
-				return
+				return nil, false
 			}
 		}
 	}
 
-	if couponInfo, exists := couponMap[code]; exists {
-		if couponInfo.OriginalPrice == requestOriginalPrice {
-			newPrice := requestOriginalPrice - float64(couponInfo.Discount)
+	return couponMap, true
+}
+
+// discountedPrice applies a coupon to a price quote, returning false if the
+// coupon does not exist or does not apply to that tier. Coupons are issued
+// against the catalog price of a tier and take their discount off whatever
+// phase price applies, the accommodation add-on is never discounted.
+func discountedPrice(code string, quote *model.PriceQuote) (float64, bool) {
+	couponMap, ok := parseCoupons()
+	if !ok {
+		return 0, false
+	}
+
+	couponInfo, exists := couponMap[strings.TrimSpace(code)]
+	if !exists || couponInfo.OriginalPrice != quote.BasePrice {
+		return 0, false
+	}
+	ticketPrice := payablePrice(quote.Price - quote.Accommodation)
+	return max(ticketPrice-float64(couponInfo.Discount), 0) + quote.Accommodation, true
+}
+
+func HandleCouponVerifications(c *gin.Context) {
+	var requestBody struct {
+		Code            string  `json:"couponCode"`
+		OriginalPrice   float64 `json:"originalPrice"`
+		Title           string  `json:"title"`
+		IsAccommodation bool    `json:"isAccommodation"`
+	}
+
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	code := strings.TrimSpace(requestBody.Code)
+
+	// Without a tier the client price is taken as the catalog price
+	quote := &model.PriceQuote{Price: requestBody.OriginalPrice, BasePrice: requestBody.OriginalPrice}
+	if requestBody.Title != "" {
+		var ok bool
+		quote, ok = resolvePrice(c, requestBody.Title, requestBody.IsAccommodation)
+		if !ok {
+			return
+		}
+	}
+
+	couponMap, ok := parseCoupons()
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon"})
+		return
+	}
+
+	if _, exists := couponMap[code]; exists {
+		if newPrice, ok := discountedPrice(code, quote); ok {
+			saved := quote.Price - newPrice
 			c.JSON(http.StatusOK, gin.H{
 				"code":          code,
-				"discount":      couponInfo.Discount,
+				"discount":      saved,
 				"newPrice":      newPrice,
-				"originalPrice": requestOriginalPrice,
-				"message":       "Congratulations! You Saved " + strconv.Itoa(couponInfo.Discount) + " on this purchase",
+				"originalPrice": quote.Price,
+				"phase":         quote.Phase,
+				"message":       "Congratulations! You Saved " + strconv.FormatFloat(saved, 'f', -1, 64) + " on this purchase",
 			})
 		} else {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Coupon does not apply to this pass"})
@@ -79,4 +120,4 @@ func HandleCouponVerifications(c *gin.Context) {
 	} else {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid Coupon code"})
 	}
-}
\ No newline at end of file
+}
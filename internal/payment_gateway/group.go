@@ -0,0 +1,158 @@
+package paymentgateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reg/internal/database"
+	emails "reg/internal/emails"
+	"reg/internal/model"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxGroupSize = 100
+
+type GroupOrderRequest struct {
+	TxnId           string              `json:"txn_id"`
+	Title           string              `json:"title"`
+	IsAccommodation bool                `json:"isAccommodation"`
+	Attendees       []model.GroupMember `json:"attendees"`
+}
+
+func CreateGroupOrder(c *gin.Context) {
+	var req GroupOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.TxnId == "" || req.Title == "" || len(req.Attendees) == 0 {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	if len(req.Attendees) > maxGroupSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A group can have at most %d attendees", maxGroupSize)})
+		return
+	}
+
+	seen := make(map[string]bool)
+	members := make([]model.GroupMember, 0, len(req.Attendees))
+	for _, a := range req.Attendees {
+		a.Email = strings.ToLower(strings.TrimSpace(a.Email))
+		a.Name = strings.TrimSpace(a.Name)
+		if a.Email == "" || a.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Every attendee needs a name and an email"})
+			return
+		}
+		if seen[a.Email] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate attendee email: " + a.Email})
+			return
+		}
+		seen[a.Email] = true
+		members = append(members, model.GroupMember{Email: a.Email, Name: a.Name, ContactNumber: a.ContactNumber})
+	}
+
+	userId, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: missing user ID"})
+		return
+	}
+
+	userIdInt, err := strconv.Atoi(userId)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid user ID"})
+		return
+	}
+
+	user, err := database.GetUserById(context.Background(), int64(userIdInt))
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	ticket, ok := resolvePrice(c, req.Title, req.IsAccommodation)
+	if !ok {
+		return
+	}
+	if payablePrice(ticket.Price) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group orders are only available for paid passes"})
+		return
+	}
+
+	holdID, ok := reserveTickets(c, userIdInt, ticket.Title, len(members), req.TxnId)
+	if !ok {
+		return
+	}
+
+	amount := ticket.Price * float64(len(members))
+	id, err := database.CreateGroupOrder(context.Background(), req.TxnId, userIdInt, amount, ticket.Title, req.IsAccommodation, ticket.Phase, members)
+	if err != nil || id == -1 {
+		if err := database.DetachHold(context.Background(), holdID); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to push transaction ID"})
+		return
+	}
+
+	if id == -1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transaction ID already exists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Group order added successfully", "group_id": id, "amount": amount})
+	//SEND EMAIL
+	data, err := emails.LoadPendingTemplate(user.Name, req.TxnId, fmt.Sprintf("%.2f", amount))
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println("TAKE ACTION>>>>>>>>>>>>>>>>>>> FOR ID: ", userIdInt)
+		return
+	}
+
+	emails.SendEmail(user.Email, nil, "Payment Confirmation Pending for E-Summit 2025", data, "")
+}
+
+func GetGroupOrders(c *gin.Context) {
+	userId, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: missing user ID"})
+		return
+	}
+
+	userIdInt, err := strconv.Atoi(userId)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid user ID"})
+		return
+	}
+
+	orders, err := database.GetGroupOrders(context.Background(), userIdInt)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"groups": orders})
+}
+
+// completeGroupOrder issues the tickets of a verified group order and
+// returns them so their passes can be mailed.
+func completeGroupOrder(txnId string) ([]*model.UserTicket, error) {
+	ticketIDs, err := database.FulfillGroupOrder(context.Background(), txnId)
+	if err != nil {
+		return nil, err
+	}
+
+	tickets := make([]*model.UserTicket, 0, len(ticketIDs))
+	for _, ticketID := range ticketIDs {
+		ticket, err := database.GetUserTicket(context.Background(), ticketID)
+		if err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, ticket)
+	}
+	return tickets, nil
+}
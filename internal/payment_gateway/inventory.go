@@ -0,0 +1,221 @@
+package paymentgateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reg/internal/database"
+	emails "reg/internal/emails"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WaitlistRequest struct {
+	Title string `json:"title"`
+}
+
+type CapacityRequest struct {
+	Title    string `json:"title"`
+	Capacity *int   `json:"capacity"`
+}
+
+// StartHoldSweeper periodically releases expired holds and hands the freed
+// seats to the waitlist.
+func StartHoldSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			titles, err := database.ExpireHolds(context.Background())
+			if err != nil {
+				log.Printf("Failed to expire ticket holds: %v\n", err)
+				continue
+			}
+			for _, title := range titles {
+				promoteWaitlist(title)
+			}
+		}
+	}()
+}
+
+// promoteWaitlist reserves free seats of a tier for waiting users and
+// notifies them.
+func promoteWaitlist(title string) {
+	promoted, err := database.PromoteWaitlist(context.Background(), title)
+	if err != nil {
+		log.Printf("Failed to promote waitlist for %s: %v\n", title, err)
+		return
+	}
+
+	for _, entry := range promoted {
+		data, err := emails.LoadWaitlistPromotionTemplate(entry.Name, entry.TicketTitle, entry.ExpiresAt)
+		if err != nil {
+			fmt.Println(err)
+			fmt.Println("TAKE ACTION>>>>>>>>>>>>>>>>>>> FOR ID: ", entry.UserID)
+			continue
+		}
+		emails.SendEmail(entry.Email, nil, "A Pass Is Reserved For You | E-Summit 2025", data, "")
+	}
+}
+
+func JoinWaitlist(c *gin.Context) {
+	var req WaitlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	userId, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: missing user ID"})
+		return
+	}
+
+	userIdInt, err := strconv.Atoi(userId)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid user ID"})
+		return
+	}
+
+	if _, err := database.GetCatalogTicket(context.Background(), req.Title); err != nil {
+		if errors.Is(err, database.ErrTicketNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown ticket type"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	position, err := database.JoinWaitlist(context.Background(), userIdInt, req.Title)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Added to the waitlist", "position": position})
+}
+
+func LeaveWaitlist(c *gin.Context) {
+	title := c.Query("title")
+	if title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing ticket title"})
+		return
+	}
+
+	userId, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: missing user ID"})
+		return
+	}
+
+	userIdInt, err := strconv.Atoi(userId)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid user ID"})
+		return
+	}
+
+	if err := database.LeaveWaitlist(context.Background(), userIdInt, title); err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Removed from the waitlist"})
+}
+
+func GetWaitlist(c *gin.Context) {
+	userId, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: missing user ID"})
+		return
+	}
+
+	userIdInt, err := strconv.Atoi(userId)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid user ID"})
+		return
+	}
+
+	entries, err := database.GetWaitlist(context.Background(), userIdInt, "")
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"waitlist": entries})
+}
+
+func GetInventory(c *gin.Context) {
+	inventory, err := database.GetInventory(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"inventory": inventory})
+}
+
+func SetTicketCapacity(c *gin.Context) {
+	var req CapacityRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Title == "" || req.Capacity == nil || *req.Capacity < -1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	err := database.SetTicketCapacity(context.Background(), req.Title, *req.Capacity)
+	if err != nil {
+		if errors.Is(err, database.ErrTicketNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown ticket type"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Capacity updated successfully"})
+	// Raising the cap may free seats for the waitlist
+	promoteWaitlist(req.Title)
+}
+
+func GetAdminWaitlist(c *gin.Context) {
+	entries, err := database.GetWaitlist(context.Background(), 0, c.Query("title"))
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"waitlist": entries})
+}
+
+func CancelTicket(c *gin.Context) {
+	ticketID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket id"})
+		return
+	}
+
+	title, err := database.CancelTicket(context.Background(), ticketID)
+	if err != nil {
+		if errors.Is(err, database.ErrTicketNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ticket cancelled successfully"})
+	promoteWaitlist(title)
+}
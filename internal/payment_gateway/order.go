@@ -2,11 +2,15 @@ package paymentgateway
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	constants "reg/internal/const"
 	"reg/internal/database"
+	"reg/internal/dispatch"
 	emails "reg/internal/emails"
+	"reg/internal/model"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -56,6 +60,18 @@ func CreateOrder(c *gin.Context) {
 		return
 	}
 
+	// Hold a seat while the user completes the payment
+	var price *model.PriceQuote
+	if req.Title != "" {
+		price, ok = resolvePrice(c, req.Title, req.IsAccommodation)
+		if !ok {
+			return
+		}
+		if _, ok := reserveTickets(c, userIdInt, req.Title, 1, ""); !ok {
+			return
+		}
+	}
+
 	// Create a new order
 	id, err := database.InitiatePayment(req.Amount, userIdInt)
 
@@ -66,7 +82,8 @@ func CreateOrder(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"order_id": id, "message": "User found",
 		"user":     user,
-		"ticketId": ticketId})
+		"ticketId": ticketId,
+		"price":    price})
 }
 
 func PushTransactionIds(c *gin.Context) {
@@ -98,12 +115,33 @@ func PushTransactionIds(c *gin.Context) {
 
 	// if amount is -1
 	if req.Amount == -1 {
+		// Only tiers that are free right now can be claimed this way
+		price, ok := resolvePrice(c, req.Title, req.IsAccommodation)
+		if !ok {
+			return
+		}
+		if price.Price > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This pass is not free"})
+			return
+		}
+
+		holdID, ok := reserveTickets(c, userIdInt, req.Title, 1, "")
+		if !ok {
+			return
+		}
+
 		err := database.AddBasicTickets(userIdInt, req.Title)
 		if err != nil {
 			fmt.Println(err)
+			if err := database.DetachHold(context.Background(), holdID); err != nil {
+				fmt.Println(err)
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add tickets", "err": err})
 			return
 		}
+		if err := database.ConvertHold(context.Background(), holdID); err != nil {
+			fmt.Println(err)
+		}
 		c.JSON(http.StatusOK, gin.H{"message": "Tickets purchased successfully"})
 
 		//SEND EMAIL
@@ -117,11 +155,38 @@ func PushTransactionIds(c *gin.Context) {
 		return
 	}
 
+	price, ok := resolvePrice(c, req.Title, req.IsAccommodation)
+	if !ok {
+		return
+	}
+
+	expected := price.Price
 	couponCode := ""
 	if req.CouponCode != "" {
 		couponCode = req.CouponCode
+		discounted, ok := discountedPrice(couponCode, price)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Coupon does not apply to this pass"})
+			return
+		}
+		expected = discounted
+	}
+
+	if req.Amount != expected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount does not match the current price", "price": expected, "phase": price.Phase})
+		return
+	}
+	holdID, ok := reserveTickets(c, userIdInt, req.Title, 1, req.TxnId)
+	if !ok {
+		return
+	}
+
+	id, err := database.CreatePaymentRecord(req.TxnId, userIdInt, req.Amount, req.Title, req.IsAccommodation, couponCode, price.Phase)
+	if err != nil || id == -1 {
+		if err := database.DetachHold(context.Background(), holdID); err != nil {
+			fmt.Println(err)
+		}
 	}
-	id, err := database.CreatePaymentRecord(req.TxnId, userIdInt, req.Amount, req.Title, req.IsAccommodation, couponCode)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to push transaction ID"})
 		return
@@ -178,6 +243,57 @@ func AddSuccessfulTxnIds(c *gin.Context) {
 		return
 	}
 
+	txnType, err := database.GetTransactionType(req.TxnId)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add tickets", "err": err})
+		return
+	}
+
+	defer func() {
+		if err := database.ConvertTransactionHolds(context.Background(), req.TxnId); err != nil {
+			fmt.Println(err)
+		}
+	}()
+
+	if txnType == "upgrade" {
+		ticket, previousTitle, err := completeUpgrade(id, req.TxnId)
+		if err != nil {
+			fmt.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upgrade ticket", "err": err})
+			return
+		}
+		// The seat of the previous tier is free again
+		defer promoteWaitlist(previousTitle)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Transaction ID verified successfully", "userId": id})
+		//REISSUE PASS
+		if ok, err := dispatch.SendPass(*ticket); !ok {
+			fmt.Println(err)
+			fmt.Println("TAKE ACTION>>>>>>>>>>>>>>>>>>> FOR ID: ", id)
+		}
+		return
+	}
+
+	if txnType == "group" {
+		tickets, err := completeGroupOrder(req.TxnId)
+		if err != nil {
+			fmt.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add tickets", "err": err})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Transaction ID verified successfully", "userId": id, "tickets": len(tickets)})
+		//SEND PASSES
+		for _, ticket := range tickets {
+			if ok, err := dispatch.SendPass(*ticket); !ok {
+				fmt.Println(err)
+				fmt.Println("TAKE ACTION>>>>>>>>>>>>>>>>>>> FOR TICKET: ", ticket.TicketID)
+			}
+		}
+		return
+	}
+
 	//Update tickets table
 	title, err := database.AddTickets(id, req.TxnId)
 	if err != nil {
@@ -203,3 +319,82 @@ func AddSuccessfulTxnIds(c *gin.Context) {
 
 	emails.SendEmail(user.Email, nil, "Your E-Summit 2025 Pass Confirmation", data, "")
 }
+
+// reserveTickets holds seats for the user, answering the request itself when
+// that is not possible.
+func reserveTickets(c *gin.Context, userID int, title string, quantity int, txnID string) (int64, bool) {
+	holdID, err := database.ReserveTickets(context.Background(), userID, title, quantity, txnID)
+	if err != nil {
+		if errors.Is(err, database.ErrSoldOut) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Sold out", "waitlist": true})
+			return 0, false
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return 0, false
+	}
+	return holdID, true
+}
+
+// resolvePrice looks up the current price of a tier, with the accommodation
+// add-on when asked for, answering the request itself when that is not
+// possible.
+func resolvePrice(c *gin.Context, title string, accommodation bool) (*model.PriceQuote, bool) {
+	price, err := database.ResolvePrice(context.Background(), title)
+	if err != nil {
+		if errors.Is(err, database.ErrTicketNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown ticket type"})
+			return nil, false
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return nil, false
+	}
+	if !accommodation {
+		return price, true
+	}
+
+	included, err := includesAccommodation(context.Background(), title)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return nil, false
+	}
+	if included {
+		return price, true
+	}
+
+	addOn, ok := accommodationPrice()
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Accommodation is not available"})
+		return nil, false
+	}
+	price.Price = payablePrice(price.Price) + addOn
+	price.Accommodation = addOn
+	return price, true
+}
+
+// accommodationPrice is the price of the accommodation add-on, read from
+// ACCOMMODATION_PRICE. The add-on is not sold when it is not set.
+func accommodationPrice() (float64, bool) {
+	price, err := strconv.ParseFloat(os.Getenv("ACCOMMODATION_PRICE"), 64)
+	if err != nil || price < 0 {
+		return 0, false
+	}
+	return price, true
+}
+
+// includesAccommodation reports whether a tier already comes with a night,
+// in which case the add-on costs nothing.
+func includesAccommodation(ctx context.Context, title string) (bool, error) {
+	entitlements, err := database.GetEntitlements(ctx, title)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range entitlements {
+		if e.Kind == "night" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
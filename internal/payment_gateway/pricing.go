@@ -0,0 +1,113 @@
+package paymentgateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reg/internal/database"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PricingPhaseRequest struct {
+	Title       string     `json:"title"`
+	Name        string     `json:"name"`
+	Price       float64    `json:"price"`
+	StartsAt    *time.Time `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at"`
+	MaxQuantity *int       `json:"max_quantity"`
+}
+
+// GetTickets lists the ticket catalog with the price that applies right now.
+func GetTickets(c *gin.Context) {
+	catalog, err := database.GetCatalog(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	tickets := make([]gin.H, 0, len(catalog))
+	for _, t := range catalog {
+		price, err := database.ResolvePrice(context.Background(), t.Title)
+		if err != nil {
+			fmt.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+			return
+		}
+		tickets = append(tickets, gin.H{
+			"title":       t.Title,
+			"description": t.Description,
+			"price":       price.Price,
+			"phase":       price.Phase,
+			"basePrice":   t.Price,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tickets": tickets})
+}
+
+func GetPricingPhases(c *gin.Context) {
+	phases, err := database.GetPricingPhases(context.Background(), c.Query("title"))
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"phases": phases})
+}
+
+func CreatePricingPhase(c *gin.Context) {
+	var req PricingPhaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Title == "" || req.Name == "" || req.Price == 0 {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Phase must end after it starts"})
+		return
+	}
+	if req.MaxQuantity != nil && *req.MaxQuantity <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity threshold must be positive"})
+		return
+	}
+
+	id, err := database.CreatePricingPhase(context.Background(), req.Title, req.Name, req.Price, req.StartsAt, req.EndsAt, req.MaxQuantity)
+	if err != nil {
+		if errors.Is(err, database.ErrTicketNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown ticket type"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pricing phase"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pricing phase created successfully", "id": id})
+}
+
+func DeletePricingPhase(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pricing phase id"})
+		return
+	}
+
+	if err := database.DeletePricingPhase(context.Background(), id); err != nil {
+		if errors.Is(err, database.ErrPhaseNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pricing phase not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pricing phase deleted successfully"})
+}
@@ -0,0 +1,218 @@
+package paymentgateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reg/internal/database"
+	emails "reg/internal/emails"
+	"reg/internal/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpgradeRequest only moves a ticket between tiers; the accommodation add-on
+// is not part of the quote and cannot be bought with an upgrade.
+type UpgradeRequest struct {
+	Title string `json:"title"`
+	TxnId string `json:"txn_id"`
+}
+
+type upgradeQuote struct {
+	Ticket *model.PurchasedTicket
+	From   *model.PriceQuote
+	To     *model.PriceQuote
+	Amount float64
+}
+
+var (
+	errNoTicket       = errors.New("You don't have a ticket to upgrade")
+	errUnknownTier    = errors.New("Unknown ticket type")
+	errNotAnUpgrade   = errors.New("Ticket can only be upgraded to a higher tier")
+	errPendingUpgrade = errors.New("An upgrade for this ticket is already awaiting verification")
+)
+
+// quoteUpgrade computes the price difference between the user's current
+// ticket and the requested tier at their current catalog prices.
+func quoteUpgrade(ctx context.Context, userID int, title string) (*upgradeQuote, error) {
+	ticket, err := database.GetUserPurchasedTicket(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrTicketNotFound) {
+			return nil, errNoTicket
+		}
+		return nil, err
+	}
+
+	to, err := database.ResolvePrice(ctx, title)
+	if err != nil {
+		if errors.Is(err, database.ErrTicketNotFound) {
+			return nil, errUnknownTier
+		}
+		return nil, err
+	}
+
+	from, err := database.ResolvePrice(ctx, ticket.TicketTitle)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := payablePrice(to.Price) - payablePrice(from.Price)
+	if amount <= 0 {
+		return nil, errNotAnUpgrade
+	}
+
+	return &upgradeQuote{Ticket: ticket, From: from, To: to, Amount: amount}, nil
+}
+
+// payablePrice treats free tickets (priced at -1) as costing nothing.
+func payablePrice(price float64) float64 {
+	if price < 0 {
+		return 0
+	}
+	return price
+}
+
+func upgradeErrorStatus(err error) int {
+	switch err {
+	case errNoTicket, errUnknownTier, errNotAnUpgrade, errPendingUpgrade:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func QuoteUpgrade(c *gin.Context) {
+	var req UpgradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	userId, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: missing user ID"})
+		return
+	}
+
+	userIdInt, err := strconv.Atoi(userId)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid user ID"})
+		return
+	}
+
+	quote, err := quoteUpgrade(context.Background(), userIdInt, req.Title)
+	if err != nil {
+		status := upgradeErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			fmt.Println(err)
+			c.JSON(status, gin.H{"error": "Internal Server Error"})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ticketId": quote.Ticket.ID,
+		"from":     quote.From.Title,
+		"to":       quote.To.Title,
+		"amount":   quote.Amount,
+		"phase":    quote.To.Phase,
+	})
+}
+
+func PushUpgradeTransaction(c *gin.Context) {
+	var req UpgradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Title == "" || req.TxnId == "" {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	userId, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: missing user ID"})
+		return
+	}
+
+	userIdInt, err := strconv.Atoi(userId)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid user ID"})
+		return
+	}
+
+	user, err := database.GetUserById(context.Background(), int64(userIdInt))
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	quote, err := quoteUpgrade(context.Background(), userIdInt, req.Title)
+	if err == nil {
+		var pending bool
+		pending, err = database.HasPendingUpgrade(quote.Ticket.ID)
+		if err == nil && pending {
+			err = errPendingUpgrade
+		}
+	}
+	if err != nil {
+		status := upgradeErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			fmt.Println(err)
+			c.JSON(status, gin.H{"error": "Internal Server Error"})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	holdID, ok := reserveTickets(c, userIdInt, quote.To.Title, 1, req.TxnId)
+	if !ok {
+		return
+	}
+
+	id, err := database.CreateUpgradeRecord(req.TxnId, userIdInt, quote.Ticket.ID, quote.Amount, quote.To.Title, quote.To.Phase)
+	if err != nil || id == -1 {
+		if err := database.DetachHold(context.Background(), holdID); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to push transaction ID"})
+		return
+	}
+
+	if id == -1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transaction ID already exists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Upgrade transaction added successfully", "payment_id": id, "amount": quote.Amount})
+	//SEND EMAIL
+	data, err := emails.LoadPendingTemplate(user.Name, req.TxnId, fmt.Sprintf("%.2f", quote.Amount))
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println("TAKE ACTION>>>>>>>>>>>>>>>>>>> FOR ID: ", userIdInt)
+		return
+	}
+
+	emails.SendEmail(user.Email, nil, "Payment Confirmation Pending for E-Summit 2025", data, "")
+}
+
+// completeUpgrade applies a verified upgrade and returns the ticket whose
+// pass has to be reissued along with the tier it was upgraded from.
+func completeUpgrade(userId int, txnId string) (*model.UserTicket, string, error) {
+	ticketID, previousTitle, err := database.ApplyUpgrade(userId, txnId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ticket, err := database.GetUserTicket(context.Background(), ticketID)
+	if err != nil {
+		return nil, "", err
+	}
+	return ticket, previousTitle, nil
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	constants "reg/internal/const"
 	"reg/internal/cookies"
+	"reg/internal/database"
+	"reg/internal/model"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -14,13 +16,29 @@ import (
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Open routes that do not require authentication
-		if c.Request.URL.Path == "/passes" ||  c.Request.URL.Path == "/logout" || strings.HasPrefix(c.Request.URL.Path, "/signup") || strings.HasPrefix(c.Request.URL.Path, "/signin") || c.Request.URL.Path == "/health" || c.Request.URL.Path == "/register" || c.Request.URL.Path == "/update-startup-sheet" {
+		if c.Request.URL.Path == "/passes" ||  c.Request.URL.Path == "/logout" || c.Request.URL.Path == "/tickets" || c.Request.URL.Path == "/transfer" || c.Request.URL.Path == "/transfer/accept" || c.Request.URL.Path == "/unsubscribe" || c.Request.URL.Path == "/calendar.ics" || strings.HasPrefix(c.Request.URL.Path, "/wallet/") || strings.HasPrefix(c.Request.URL.Path, "/signup") || strings.HasPrefix(c.Request.URL.Path, "/signin") || c.Request.URL.Path == "/health" || c.Request.URL.Path == "/register" || c.Request.URL.Path == "/update-startup-sheet" {
 			c.Next()
 			return
 		}
 
 		// Get the Authorization header
 		authHeader := c.GetHeader("Authorization")
+
+		// Browsers cannot set headers on an EventSource, so the check-in
+		// stream also takes a short-lived stream ticket as a query parameter
+		if authHeader == "" && c.Request.URL.Path == "/admin/checkins/stream" && c.Query("ticket") != "" {
+			admin, err := cookies.ParseStreamTicket(c.Query("ticket"))
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: invalid stream ticket"})
+				c.Abort()
+				return
+			}
+			ctx := context.WithValue(c.Request.Context(), constants.EmailKey, "ADMIN")
+			ctx = context.WithValue(ctx, constants.AdminKey, admin)
+			c.Request = c.Request.WithContext(ctx)
+			c.Next()
+			return
+		}
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: missing Authorization header"})
 			c.Abort()
@@ -36,8 +54,22 @@ func AuthMiddleware() gin.HandlerFunc {
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 
 		// Verify token
-		if token == os.Getenv("ADMIN_TOKEN") {
+		if admin, ok := adminForToken(token); ok {
 			ctx := context.WithValue(c.Request.Context(), constants.EmailKey, "ADMIN")
+			ctx = context.WithValue(ctx, constants.AdminKey, admin)
+			c.Request = c.Request.WithContext(ctx)
+			c.Next()
+			return
+		}
+
+		if strings.HasPrefix(token, database.ScannerTokenPrefix) {
+			scanner, err := database.GetScannerByToken(context.Background(), token)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: invalid scanner token"})
+				c.Abort()
+				return
+			}
+			ctx := context.WithValue(c.Request.Context(), constants.ScannerKey, *scanner)
 			c.Request = c.Request.WithContext(ctx)
 			c.Next()
 			return
@@ -60,6 +92,50 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// adminForToken returns the name of the admin owning the token. ADMIN_TOKEN
+// belongs to "admin", ADMIN_TOKENS lists personal tokens as name:token pairs
+// separated by commas.
+func adminForToken(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	if token == os.Getenv("ADMIN_TOKEN") {
+		return "admin", true
+	}
+	for _, entry := range strings.Split(os.Getenv("ADMIN_TOKENS"), ",") {
+		name, adminToken, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if ok && name != "" && adminToken != "" && adminToken == token {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// AdminMiddleware only lets requests authenticated with the admin token through.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email, ok := GetUserEmail(c)
+		if !ok || email != "ADMIN" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: invalid email"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// ScannerMiddleware only lets requests from an active gate scanner through.
+func ScannerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Request.Context().Value(constants.ScannerKey).(model.Scanner); !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: scanner token required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 func GetUserID(c *gin.Context) (string, bool) {
 	userID, ok := c.Request.Context().Value(constants.UserIDKey).(string)
 	return userID, ok
@@ -49,15 +49,94 @@ func (s *Server) RegisterRoutes() http.Handler {
 	}
 
 	s.GET("/me", controllers.GetUserHandler)
+	s.GET("/me/pass.png", controllers.GetPassPNGHandler)
+	s.GET("/me/pass.svg", controllers.GetPassSVGHandler)
+	s.GET("/me/calendar.ics", controllers.GetMyCalendarHandler)
+	s.GET("/calendar.ics", controllers.GetCalendarHandler)
+	s.PUT("/me/gender", controllers.SetGenderHandler)
+	s.GET("/me/preferences", controllers.GetEmailPreferencesHandler)
+	s.PUT("/me/preferences", controllers.SetEmailPreferencesHandler)
+	s.POST("/me/transfer", controllers.CreateTransferHandler)
+	s.GET("/me/transfer", controllers.GetTransferHandler)
+	s.DELETE("/me/transfer", controllers.CancelTransferHandler)
+	s.GET("/transfer", controllers.GetTransferOfferHandler)
+	s.POST("/transfer/accept", controllers.AcceptTransferHandler)
+	s.GET("/unsubscribe", controllers.UnsubscribePageHandler)
+	s.POST("/unsubscribe", controllers.UnsubscribeHandler)
+	s.GET("/wallet/:code/apple", controllers.AppleWalletHandler)
+	s.GET("/wallet/:code/google", controllers.GoogleWalletHandler)
 	s.GET("/logout", controllers.LogoutHandler)
 
 	s.POST("/paymentInitiate", paymentgateway.CreateOrder)
 	s.POST("/transactionID", paymentgateway.PushTransactionIds)
+	s.GET("/tickets", paymentgateway.GetTickets)
 	s.POST("/applyCoupon", paymentgateway.HandleCouponVerifications)
+	s.POST("/upgrade/quote", paymentgateway.QuoteUpgrade)
+	s.POST("/upgrade", paymentgateway.PushUpgradeTransaction)
+	s.POST("/group", paymentgateway.CreateGroupOrder)
+	s.GET("/group", paymentgateway.GetGroupOrders)
+	s.POST("/waitlist", paymentgateway.JoinWaitlist)
+	s.GET("/waitlist", paymentgateway.GetWaitlist)
+	s.DELETE("/waitlist", paymentgateway.LeaveWaitlist)
 
-	admin := s.Group("/admin")
+	s.POST("/checkin", ScannerMiddleware(), controllers.CheckInHandler)
+
+	scanner := s.Group("/scanner", ScannerMiddleware())
+	{
+		scanner.GET("/manifest", controllers.ScannerManifestHandler)
+		scanner.POST("/sync", controllers.SyncScansHandler)
+	}
+
+	admin := s.Group("/admin", AdminMiddleware())
 	{
 		admin.POST("/transactionID", paymentgateway.AddSuccessfulTxnIds)
+		admin.GET("/inventory", paymentgateway.GetInventory)
+		admin.PUT("/inventory", paymentgateway.SetTicketCapacity)
+		admin.GET("/waitlist", paymentgateway.GetAdminWaitlist)
+		admin.POST("/tickets/:id/cancel", paymentgateway.CancelTicket)
+		admin.GET("/pricing", paymentgateway.GetPricingPhases)
+		admin.POST("/pricing", paymentgateway.CreatePricingPhase)
+		admin.DELETE("/pricing/:id", paymentgateway.DeletePricingPhase)
+		admin.POST("/complimentary", controllers.IssueComplimentaryPasses)
+		admin.GET("/reports", controllers.ListReportsHandler)
+		admin.GET("/reports/:name", controllers.GetReportHandler)
+		admin.GET("/passes/verify", controllers.VerifyPassHandler)
+		admin.POST("/passes/dispatch", controllers.SendPassesHandler)
+		admin.GET("/passes/dispatch", controllers.GetPassDispatchHandler)
+		admin.POST("/passes/dispatch/sent", controllers.MarkPassesSentHandler)
+		admin.GET("/outbox", controllers.GetOutboxHandler)
+		admin.POST("/outbox/retry", controllers.RetryDeadEmailsHandler)
+		admin.GET("/outbox/:id", controllers.GetOutboxEmailHandler)
+		admin.POST("/outbox/:id/retry", controllers.RetryOutboxEmailHandler)
+		admin.POST("/outbox/:id/resend", controllers.ResendOutboxEmailHandler)
+		admin.GET("/bounces", controllers.GetBouncesHandler)
+		admin.POST("/bounces/scan", controllers.ScanBouncesHandler)
+		admin.POST("/bounces/:user_id/clear", controllers.ClearBounceHandler)
+		admin.GET("/campaigns", controllers.GetCampaignsHandler)
+		admin.POST("/campaigns", controllers.CreateCampaignHandler)
+		admin.POST("/campaigns/preview", controllers.PreviewCampaignHandler)
+		admin.GET("/campaigns/:id", controllers.GetCampaignHandler)
+		admin.GET("/campaigns/:id/recipients", controllers.GetCampaignRecipientsHandler)
+		admin.POST("/campaigns/:id/send", controllers.SendCampaignHandler)
+		admin.POST("/campaigns/:id/cancel", controllers.CancelCampaignHandler)
+		admin.GET("/scanners", controllers.GetScannersHandler)
+		admin.POST("/scanners", controllers.CreateScannerHandler)
+		admin.POST("/scanners/:id/deactivate", controllers.DeactivateScannerHandler)
+		admin.GET("/entitlements", controllers.GetEntitlementsHandler)
+		admin.PUT("/entitlements", controllers.SetEntitlementHandler)
+		admin.DELETE("/entitlements", controllers.DeleteEntitlementHandler)
+		admin.GET("/checkins/stream", controllers.CheckInStreamHandler)
+		admin.POST("/checkins/stream/ticket", controllers.CheckInStreamTicketHandler)
+		admin.GET("/checkins/snapshot", controllers.CheckInSnapshotHandler)
+		admin.GET("/hostels", controllers.GetHostelsHandler)
+		admin.POST("/hostels", controllers.CreateHostelHandler)
+		admin.PUT("/hostels/:id/rooms", controllers.SetRoomsHandler)
+		admin.GET("/accommodation", controllers.GetAllocationsHandler)
+		admin.POST("/accommodation/allocate", controllers.AllocateRoomsHandler)
+		admin.PUT("/accommodation/:ticket_id", controllers.AssignRoomHandler)
+		admin.DELETE("/accommodation/:ticket_id", controllers.ReleaseRoomHandler)
+		admin.POST("/accommodation/checkin", controllers.HostelCheckInHandler)
+		admin.POST("/accommodation/checkout", controllers.HostelCheckOutHandler)
 	}
 
 	return s
@@ -3,11 +3,17 @@ package server
 import (
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/joho/godotenv/autoload"
 
+	"reg/internal/bounces"
+	"reg/internal/campaigns"
 	"reg/internal/database"
+	"reg/internal/dispatch"
+	email "reg/internal/emails"
+	paymentgateway "reg/internal/payment_gateway"
 )
 
 type Server struct {
@@ -18,6 +24,11 @@ type Server struct {
 func NewServer() *Server {
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
 	database.New()
+	dispatch.Recover()
+	email.StartOutbox()
+	campaigns.Resume()
+	bounces.Start()
+	paymentgateway.StartHoldSweeper(time.Minute)
 
 	server := &Server{
 		port:   port,
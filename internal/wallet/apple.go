@@ -0,0 +1,226 @@
+package wallet
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"reg/internal/passes"
+	"reg/templates"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrAppleNotConfigured = errors.New("apple wallet is not configured")
+
+// Images bundled into every pass from the embedded templates, Apple
+// requires at least an icon
+var passImages = map[string]string{
+	"icon.png": "image.png",
+	"logo.png": "image.png",
+}
+
+type appleSigner struct {
+	cert  *x509.Certificate
+	key   crypto.Signer
+	chain []*x509.Certificate
+}
+
+// AppleEnabled reports whether the pass type and signing certificate are
+// configured.
+func AppleEnabled() bool {
+	return os.Getenv("PASS_TYPE_ID") != "" && os.Getenv("TEAM_ID") != "" &&
+		os.Getenv("WALLET_CERT") != "" && os.Getenv("WALLET_KEY") != ""
+}
+
+// loadAppleSigner reads the pass certificate and key from the PEM files in
+// WALLET_CERT and WALLET_KEY, and the Apple WWDR intermediate from
+// WALLET_WWDR_CERT when set. They are read once.
+var loadAppleSigner = sync.OnceValues(func() (*appleSigner, error) {
+	if !AppleEnabled() {
+		return nil, ErrAppleNotConfigured
+	}
+
+	certs, err := readCertificates(os.Getenv("WALLET_CERT"))
+	if err != nil {
+		return nil, err
+	}
+	key, err := readPrivateKey(os.Getenv("WALLET_KEY"))
+	if err != nil {
+		return nil, err
+	}
+
+	signer := &appleSigner{cert: certs[0], key: key, chain: certs[1:]}
+	if path := os.Getenv("WALLET_WWDR_CERT"); path != "" {
+		wwdr, err := readCertificates(path)
+		if err != nil {
+			return nil, err
+		}
+		signer.chain = append(signer.chain, wwdr...)
+	}
+	return signer, nil
+})
+
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+	return certs, nil
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no private key found in %s", path)
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key in %s", path)
+	}
+	return signer, nil
+}
+
+type passField struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+type passBarcode struct {
+	Format          string `json:"format"`
+	Message         string `json:"message"`
+	MessageEncoding string `json:"messageEncoding"`
+	AltText         string `json:"altText,omitempty"`
+}
+
+// passJSON builds the pass.json of an event ticket.
+func passJSON(p Pass) ([]byte, error) {
+	format := "PKBarcodeFormatQR"
+	if passes.Symbology() == passes.SymbologyCode128 {
+		format = "PKBarcodeFormatCode128"
+	}
+	barcode := passBarcode{Format: format, Message: p.Code, MessageEncoding: "iso-8859-1", AltText: p.Name}
+
+	pass := map[string]any{
+		"formatVersion":      1,
+		"passTypeIdentifier": os.Getenv("PASS_TYPE_ID"),
+		"teamIdentifier":     os.Getenv("TEAM_ID"),
+		"serialNumber":       strconv.Itoa(p.TicketID),
+		"organizationName":   organizationName,
+		"description":        eventName + " Pass",
+		"logoText":           eventName,
+		"foregroundColor":    "rgb(255, 255, 255)",
+		"backgroundColor":    "rgb(20, 20, 20)",
+		"labelColor":         "rgb(200, 200, 200)",
+		"barcode":            barcode,
+		"barcodes":           []passBarcode{barcode},
+		"eventTicket": map[string][]passField{
+			"primaryFields":   {{Key: "name", Label: "ATTENDEE", Value: p.Name}},
+			"secondaryFields": {{Key: "tier", Label: "PASS", Value: p.TicketTitle}},
+			"auxiliaryFields": {{Key: "dates", Label: "DATES", Value: eventDates(p.Start, p.End)}, {Key: "venue", Label: "VENUE", Value: eventVenue}},
+			"backFields":      {{Key: "ticket", Label: "Ticket number", Value: strconv.Itoa(p.TicketID)}},
+		},
+	}
+	// Wallets surface the pass around the start of the event
+	if !p.Start.IsZero() {
+		pass["relevantDate"] = p.Start.Format(time.RFC3339)
+	}
+	return json.Marshal(pass)
+}
+
+// ApplePass builds a signed .pkpass bundle for a ticket.
+func ApplePass(p Pass) ([]byte, error) {
+	signer, err := loadAppleSigner()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	if files["pass.json"], err = passJSON(p); err != nil {
+		return nil, err
+	}
+	for name, path := range passImages {
+		if files[name], err = templates.FS.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read pass image: %w", err)
+		}
+	}
+
+	// The manifest lists the SHA-1 of every file and is what gets signed
+	manifest := make(map[string]string, len(files))
+	for name, data := range files {
+		sum := sha1.Sum(data)
+		manifest[name] = hex.EncodeToString(sum[:])
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signDetached(manifestJSON, signer.cert, signer.key, signer.chain)
+	if err != nil {
+		return nil, err
+	}
+	files["manifest.json"] = manifestJSON
+	files["signature"] = signature
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
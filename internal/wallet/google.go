@@ -0,0 +1,108 @@
+package wallet
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reg/internal/passes"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var ErrGoogleNotConfigured = errors.New("google wallet is not configured")
+
+const googleSaveURL = "https://pay.google.com/gp/v/save/"
+
+type googleSigner struct {
+	email string
+	key   *rsa.PrivateKey
+}
+
+// GoogleEnabled reports whether a Google Wallet issuer and service account
+// are configured.
+func GoogleEnabled() bool {
+	return os.Getenv("GOOGLE_WALLET_ISSUER_ID") != "" && os.Getenv("GOOGLE_WALLET_KEY_FILE") != ""
+}
+
+// googleClassID is the event ticket class passes are issued under, it has
+// to exist in the issuer's Google Pay console.
+func googleClassID() string {
+	class := os.Getenv("GOOGLE_WALLET_CLASS_ID")
+	if class == "" {
+		class = "esummit25"
+	}
+	return os.Getenv("GOOGLE_WALLET_ISSUER_ID") + "." + class
+}
+
+// loadGoogleSigner reads the service account key file in
+// GOOGLE_WALLET_KEY_FILE once.
+var loadGoogleSigner = sync.OnceValues(func() (*googleSigner, error) {
+	if !GoogleEnabled() {
+		return nil, ErrGoogleNotConfigured
+	}
+
+	data, err := os.ReadFile(os.Getenv("GOOGLE_WALLET_KEY_FILE"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read google wallet key: %w", err)
+	}
+	var account struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+	}
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("failed to parse google wallet key: %w", err)
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse google wallet key: %w", err)
+	}
+	return &googleSigner{email: account.ClientEmail, key: key}, nil
+})
+
+// GoogleSaveURL returns a "Save to Google Wallet" link carrying the ticket
+// as a signed JWT.
+func GoogleSaveURL(p Pass) (string, error) {
+	signer, err := loadGoogleSigner()
+	if err != nil {
+		return "", err
+	}
+
+	barcodeType := "QR_CODE"
+	if passes.Symbology() == passes.SymbologyCode128 {
+		barcodeType = "CODE_128"
+	}
+
+	object := map[string]any{
+		"id":               fmt.Sprintf("%s.ticket-%d", os.Getenv("GOOGLE_WALLET_ISSUER_ID"), p.TicketID),
+		"classId":          googleClassID(),
+		"state":            "ACTIVE",
+		"ticketHolderName": p.Name,
+		"ticketNumber":     fmt.Sprint(p.TicketID),
+		"ticketType": map[string]any{
+			"defaultValue": map[string]string{"language": "en-US", "value": p.TicketTitle},
+		},
+		"barcode": map[string]string{
+			"type":          barcodeType,
+			"value":         p.Code,
+			"alternateText": p.Name,
+		},
+	}
+
+	claims := jwt.MapClaims{
+		"iss":     signer.email,
+		"aud":     "google",
+		"typ":     "savetowallet",
+		"iat":     time.Now().Unix(),
+		"origins": []string{},
+		"payload": map[string]any{"eventTicketObjects": []any{object}},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(signer.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign google wallet pass: %w", err)
+	}
+	return googleSaveURL + token, nil
+}
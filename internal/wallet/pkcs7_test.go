@@ -0,0 +1,116 @@
+package wallet
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func selfSigned(t *testing.T, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2025),
+		Subject:      pkix.Name{CommonName: "Pass Type ID: pass.test.esummit"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestSignDetached(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte(`{"pass.json":"0123"}`)
+	for _, key := range []crypto.Signer{rsaKey, ecKey} {
+		cert := selfSigned(t, key)
+		der, err := signDetached(content, cert, key, nil)
+		if err != nil {
+			t.Fatalf("%T: %v", key, err)
+		}
+
+		var ci contentInfo
+		if _, err := asn1.Unmarshal(der, &ci); err != nil {
+			t.Fatalf("%T: parse content info: %v", key, err)
+		}
+		if !ci.ContentType.Equal(oidSignedData) {
+			t.Fatalf("%T: content type %v, want signed data", key, ci.ContentType)
+		}
+		var sd signedData
+		if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+			t.Fatalf("%T: parse signed data: %v", key, err)
+		}
+		if len(sd.SignerInfos) != 1 {
+			t.Fatalf("%T: %d signer infos, want 1", key, len(sd.SignerInfos))
+		}
+		embedded, err := x509.ParseCertificate(sd.Certificates.Bytes)
+		if err != nil || !embedded.Equal(cert) {
+			t.Fatalf("%T: signing certificate not embedded: %v", key, err)
+		}
+
+		// Verify the signature over the attributes re-encoded as a SET
+		si := sd.SignerInfos[0]
+		attributes, err := asn1.Marshal(setOf(si.AuthenticatedAttributes.Bytes))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cert.CheckSignature(signatureAlgorithm(key), attributes, si.EncryptedDigest); err != nil {
+			t.Fatalf("%T: signature does not verify: %v", key, err)
+		}
+
+		digest := sha256.Sum256(content)
+		if !containsDigest(t, si.AuthenticatedAttributes.Bytes, digest[:]) {
+			t.Fatalf("%T: message digest attribute does not match content", key)
+		}
+	}
+}
+
+func signatureAlgorithm(key crypto.Signer) x509.SignatureAlgorithm {
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		return x509.SHA256WithRSA
+	}
+	return x509.ECDSAWithSHA256
+}
+
+func containsDigest(t *testing.T, attributes, digest []byte) bool {
+	t.Helper()
+	for rest := attributes; len(rest) > 0; {
+		var a attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &a); err != nil {
+			t.Fatal(err)
+		}
+		if !a.Type.Equal(oidMessageDigest) {
+			continue
+		}
+		var value []byte
+		if _, err := asn1.Unmarshal(a.Value.Bytes, &value); err != nil {
+			t.Fatal(err)
+		}
+		return string(value) == string(digest)
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package wallet
+
+import (
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Pass is what goes on a wallet pass. Start and End span the event days of
+// the schedule, in the venue's time zone.
+type Pass struct {
+	TicketID    int
+	Name        string
+	TicketTitle string
+	Code        string
+	Start       time.Time
+	End         time.Time
+}
+
+const (
+	organizationName = "E-Cell IIT Hyderabad"
+	eventName        = "E-Summit 2025"
+	eventVenue       = "IIT Hyderabad"
+)
+
+// eventDates shows the days from start to end, like "8-9 February 2025".
+func eventDates(start, end time.Time) string {
+	switch {
+	case start.IsZero():
+		return ""
+	case start.Year() != end.Year():
+		return start.Format("2 January 2006") + " - " + end.Format("2 January 2006")
+	case start.Month() != end.Month():
+		return start.Format("2 January") + " - " + end.Format("2 January 2006")
+	case start.Day() != end.Day():
+		return start.Format("2") + "-" + end.Format("2 January 2006")
+	}
+	return start.Format("2 January 2006")
+}
+
+// Links returns the public URLs to add a pass to Apple and Google Wallet.
+// Each is empty when that wallet is not configured. The pass code in the
+// URL is what authorises the download, so the links need no session.
+func Links(code string) (apple, google string) {
+	base := strings.TrimRight(os.Getenv("PUBLIC_API_URL"), "/")
+	if base == "" {
+		return "", ""
+	}
+	escaped := url.PathEscape(code)
+	if AppleEnabled() {
+		apple = base + "/wallet/" + escaped + "/apple"
+	}
+	if GoogleEnabled() {
+		google = base + "/wallet/" + escaped + "/google"
+	}
+	return apple, google
+}
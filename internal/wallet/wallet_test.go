@@ -0,0 +1,27 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventDates(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2025, month, d, 9, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		start, end time.Time
+		want       string
+	}{
+		{day(time.February, 8), day(time.February, 9), "8-9 February 2025"},
+		{day(time.February, 8), day(time.February, 8), "8 February 2025"},
+		{day(time.January, 31), day(time.February, 1), "31 January - 1 February 2025"},
+		{time.Time{}, time.Time{}, ""},
+	}
+	for _, tt := range tests {
+		if got := eventDates(tt.start, tt.end); got != tt.want {
+			t.Errorf("eventDates(%v, %v) = %q, want %q", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+// Package templates embeds the email templates and images in the binary.
+package templates
+
+import "embed"
+
+//go:embed *.html image.png
+var FS embed.FS